@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"runtime"
+
+	"github.com/chronotrax/knapsack/knapsack"
+)
+
+// readAttackInput reads the public key and ciphertext shared by attack and bruteforce.
+func readAttackInput(key, in string, stdin io.Reader) (knapsack.PublicKey, knapsack.Ciphertext, error) {
+	public, err := readPublicKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := openInput(in, stdin)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	_, cipher, err := knapsack.ReadContainer(public, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return public, cipher, nil
+}
+
+func attack(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("attack", "-key knapsack.pub [-in file] [-lattice l] [-scale n] [-expected file] [-workers n] [-v]",
+		"Runs Shamir's lattice attack against every block of a ciphertext, using only the public key:\n"+
+			"a suspected plaintext block is verified by encrypting it again.", stderr)
+	key := fs.String("key", "", "public key PEM file")
+	in := fs.String("in", stdio, "ciphertext container written by encrypt, - for stdin")
+	latticeName := fs.String("lattice", "lagarias-odlyzko", "lattice to reduce: lagarias-odlyzko or cjloss (see knapsack.Lattice)")
+	scaleStr := fs.String("scale", "", "factor N multiplying the public key row of the lattice (default: 1 for lagarias-odlyzko, ceil(sqrt(n)) for cjloss)")
+	expected := fs.String("expected", "", "file holding the original plaintext, to compare the recovered plaintext with")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of blocks to attack at once")
+	verbose := fs.Bool("v", false, "log the initial and reduced lattice bases to stderr")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := required(fs, "key"); err != nil {
+		return err
+	}
+
+	lattice, err := parseLattice(*latticeName)
+	if err != nil {
+		return err
+	}
+
+	opts := knapsack.AttackOptions{Lattice: lattice, Workers: *workers}
+	if *scaleStr != "" {
+		scale, success := new(big.Int).SetString(*scaleStr, 10)
+		if !success {
+			return fmt.Errorf("scale is not an integer: %q", *scaleStr)
+		}
+		opts.Scale = scale
+	}
+
+	public, cipher, err := readAttackInput(*key, *in, stdin)
+	if err != nil {
+		return err
+	}
+
+	if *verbose {
+		opts.Logger = log.New(stderr, "", 0)
+	}
+
+	result, err := knapsack.Attack(cipher, public, opts)
+	if err != nil {
+		return err
+	}
+
+	printAttackResult(stdout, result)
+
+	if *expected != "" {
+		want, err := os.ReadFile(*expected)
+		if err != nil {
+			return err
+		}
+
+		data, err := result.Data()
+		fmt.Fprintln(stdout, "matches expected plaintext:", err == nil && bytes.Equal(data, want))
+	}
+
+	return nil
+}
+
+// parseLattice returns the knapsack.Lattice named by its String.
+func parseLattice(name string) (knapsack.Lattice, error) {
+	switch name {
+	case knapsack.LagariasOdlyzkoLattice.String():
+		return knapsack.LagariasOdlyzkoLattice, nil
+	case knapsack.CJLOSSLattice.String():
+		return knapsack.CJLOSSLattice, nil
+	default:
+		return 0, fmt.Errorf("unknown lattice %q, want %v or %v", name, knapsack.LagariasOdlyzkoLattice, knapsack.CJLOSSLattice)
+	}
+}
+
+// printAttackResult prints every candidate of result, and whether it encrypts back to its ciphertext block.
+func printAttackResult(w io.Writer, result *knapsack.AttackResult) {
+	for _, b := range result.Blocks {
+		fmt.Fprintf(w, "block %d: %d LLL iterations, %d size reductions, %d swaps, reduced: %v\n",
+			b.Index, b.Reduction.Iterations, b.Reduction.SizeReductions, b.Reduction.Swaps, b.Reduction.Reduced)
+
+		if len(b.Candidates) == 0 {
+			fmt.Fprintln(w, "no suspected plaintext found in reduced matrix")
+		}
+		for _, c := range b.Candidates {
+			fmt.Fprintf(w, "suspected plaintext found at column %d: %0*b, verified: %v\n",
+				c.Column, result.Bits, c.Block, c.Verified)
+		}
+	}
+
+	fmt.Fprintf(w, "recovered: %v, time taken: %v\n", result.Recovered(), result.Duration)
+	if data, err := result.Data(); err == nil {
+		fmt.Fprintf(w, "recovered plaintext: %q\n", data)
+	}
+}
+
+func bruteforce(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("bruteforce", "-key knapsack.pub [-max-keys n] [-in file] [-workers n]",
+		"Brute forces private keys (v, u) that decrypt a ciphertext to a plaintext\n"+
+			"that encrypts back to it under the public key. Progress is logged to stderr.", stderr)
+	key := fs.String("key", "", "public key PEM file")
+	in := fs.String("in", stdio, "ciphertext container written by encrypt, - for stdin")
+	maxKeys := fs.Uint64("max-keys", 5, "max # of keys to brute force before stopping")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of moduli u to try at once")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := required(fs, "key"); err != nil {
+		return err
+	}
+
+	public, cipher, err := readAttackInput(*key, *in, stdin)
+	if err != nil {
+		return err
+	}
+
+	result, err := knapsack.BruteForce(cipher, public, knapsack.BruteForceOptions{
+		MaxKeys: *maxKeys,
+		Workers: *workers,
+		Logger:  log.New(stderr, "", 0),
+	})
+	if err != nil {
+		return err
+	}
+
+	printBruteForceResult(stdout, result)
+
+	return nil
+}
+
+// printBruteForceResult prints every key of result and the plaintext it decrypts to.
+func printBruteForceResult(w io.Writer, result *knapsack.BruteForceResult) {
+	for _, k := range result.Keys {
+		fmt.Fprintf(w, "time taken: %v, found private key! v=%d u=%d\n", k.Elapsed, k.Private.V, k.Private.U)
+		if k.Data != nil {
+			fmt.Fprintf(w, "recovered plaintext: %q\n", k.Data)
+		}
+	}
+
+	fmt.Fprintln(w, "# of valid keys found:", len(result.Keys))
+}
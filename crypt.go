@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/chronotrax/knapsack/knapsack"
+)
+
+func encrypt(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("encrypt", "-key knapsack.pub [-in file] [-out file]",
+		"Encrypts a file with a public key into a container recording the block size and number of blocks.", stderr)
+	key := fs.String("key", "", "public key PEM file")
+	in := fs.String("in", stdio, "file to encrypt, - for stdin")
+	out := fs.String("out", stdio, "file to write the ciphertext container to, - for stdout")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := required(fs, "key"); err != nil {
+		return err
+	}
+
+	public, err := readCryptosystemPublicKey(*key)
+	if err != nil {
+		return err
+	}
+
+	r, size, err := openSizedInput(*in, stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := createOutput(*out, stdout)
+	if err != nil {
+		return err
+	}
+
+	if err := knapsack.EncryptCryptosystemContainer(public, w, r, size); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// openSizedInput opens path like openInput and returns its size.
+// stdin has no size, so it is read into memory.
+func openSizedInput(path string, stdin io.Reader) (io.ReadCloser, int64, error) {
+	if path == stdio {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, 0, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	if !info.Mode().IsRegular() {
+		f.Close()
+		return nil, 0, fmt.Errorf("%s is not a regular file", path)
+	}
+
+	return f, info.Size(), nil
+}
+
+func decrypt(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("decrypt", "-key knapsack.key [-passphrase-file file] [-in file] [-out file]",
+		"Decrypts a ciphertext container written by the encrypt command with a private key.", stderr)
+	key := fs.String("key", "", "private key PEM file")
+	passphraseFile := fs.String("passphrase-file", "", "file holding the passphrase of an encrypted private key")
+	in := fs.String("in", stdio, "file to decrypt, - for stdin")
+	out := fs.String("out", stdio, "file to write the plaintext to, - for stdout")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := required(fs, "key"); err != nil {
+		return err
+	}
+
+	private, err := readPrivateKey(*key, *passphraseFile)
+	if err != nil {
+		return err
+	}
+
+	r, err := openInput(*in, stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// a wrong key or a corrupted container must not replace an existing -out with partial plaintext
+	w, err := createPendingOutput(*out, stdout)
+	if err != nil {
+		return err
+	}
+
+	if err := knapsack.DecryptCryptosystemContainer(private, w, r); err != nil {
+		w.discard()
+		return err
+	}
+
+	return w.commit()
+}
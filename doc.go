@@ -0,0 +1,74 @@
+/*
+Knapsack is a command line tool for the Merkle–Hellman knapsack cryptosystem.
+
+Usage:
+
+	knapsack <command> [flags]
+
+The commands are:
+
+	keygen       generate a key pair
+	encrypt      encrypt data with a public key
+	decrypt      decrypt data with a private key
+	attack       run Shamir's lattice attack against ciphertext
+	bruteforce   brute force private keys for ciphertext
+	inspect      print a key or ciphertext file, with a density report for keys
+
+Run "knapsack <command> -h" for a command's flags.
+
+# Key files
+
+keygen writes the public key (knapsack.pub by default) and the private key
+(knapsack.key by default) to separate PEM files:
+
+	-----BEGIN KNAPSACK PUBLIC KEY-----
+	Bits: 8
+	Checksum: ebca07ff
+
+	S05BUAMBCAgCAp4BJwICnwFBAgEvAXUCAe4B6g==
+	-----END KNAPSACK PUBLIC KEY-----
+
+Bits is the number of bits encrypted per block and Checksum is the
+CRC-32 of the decoded body. Keys written by older versions have a Block-Size
+header in bytes instead, and are still read. The body is the key's binary encoding (see
+knapsack.PublicKey.MarshalBinary):
+
+	"KNAP" | version | kind | uvarint bits | [V | U] | uvarint count | values... | [uvarint count | perm...] | [uvarint count | (V | U)...]
+
+where V, U and every value are a uvarint length followed by big-endian bytes.
+V, U and perm are only present in a private key, whose values are its
+superincreasing set. A public key has one value per plaintext bit, the i-th
+built from set value perm[i] so that it doesn't reveal the set's order.
+Random keys always get a random perm, custom keys only with -perm.
+The trailing (V, U) pairs are the rounds of the iterated scheme, each
+multiplying the knapsack again modulo a larger U. keygen -rounds n adds n
+of them to a random key.
+
+A private key written with -passphrase-file uses the
+"ENCRYPTED KNAPSACK PRIVATE KEY" type, its body sealed with AES-256-GCM
+under an Argon2id key (see knapsack.EncryptPrivateKey).
+
+Keys generated with the same -seed, -block-size (or -bits) and -max-gap are
+byte-identical, unless the private key is encrypted (see knapsack.NewSeededRand).
+-bits allows block sizes that are not whole bytes. -generator picks how the
+random superincreasing set grows: by small gaps (the default), up to a bit
+length per value, as in Merkle and Hellman's paper, or towards a target
+public key density (see knapsack.SetGenerator).
+
+keygen -scheme picks another cryptosystem, chor-rivest or naccache-stern
+(see knapsack.Schemes). Their key files add a Scheme header and hold the
+key as JSON instead (see knapsack.WriteCryptosystemPublicKeyPEM); encrypt and
+decrypt read the scheme from it. Merkle–Hellman keys never have the header.
+
+# Ciphertext files
+
+encrypt writes a container holding the block size in bits and number of blocks
+before the ciphertext (see knapsack.ContainerHeader):
+
+	"KNAP" | version | kind | uvarint bits | uvarint blocks | blocks...
+
+Every block is a uvarint length followed by big-endian bytes. The plaintext is
+padded with a 1 bit and 0's to fill its last block, so a file of n bytes takes
+8n/bits+1 blocks. decrypt fails if the number of blocks does not match.
+*/
+package main
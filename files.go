@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chronotrax/knapsack/knapsack"
+)
+
+// stdio is the path meaning stdin or stdout.
+const stdio = "-"
+
+// nopCloser wraps stdout so closing it is a no-op.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// openInput opens path for reading, or returns stdin if path is stdio.
+func openInput(path string, stdin io.Reader) (io.ReadCloser, error) {
+	if path == stdio {
+		return io.NopCloser(stdin), nil
+	}
+
+	return os.Open(path)
+}
+
+// createOutput creates path for writing, or returns stdout if path is stdio.
+func createOutput(path string, stdout io.Writer) (io.WriteCloser, error) {
+	if path == stdio {
+		return nopCloser{stdout}, nil
+	}
+
+	return os.Create(path)
+}
+
+// pendingOutput is an output file written to a temporary file in the same directory,
+// so path is only replaced once the output is complete.
+type pendingOutput struct {
+	io.Writer
+	f    *os.File // nil for stdout
+	path string
+}
+
+// createPendingOutput creates a temporary file next to path, readable only by its owner,
+// or returns stdout if path is stdio. Either commit or discard must be called.
+func createPendingOutput(path string, stdout io.Writer) (*pendingOutput, error) {
+	if path == stdio {
+		return &pendingOutput{Writer: stdout}, nil
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &pendingOutput{Writer: f, f: f, path: path}, nil
+}
+
+// commit closes o and renames it to its path.
+func (o *pendingOutput) commit() error {
+	if o.f == nil {
+		return nil
+	}
+
+	if err := o.f.Close(); err != nil {
+		os.Remove(o.f.Name())
+		return err
+	}
+
+	if err := os.Rename(o.f.Name(), o.path); err != nil {
+		os.Remove(o.f.Name())
+		return err
+	}
+
+	return nil
+}
+
+// discard closes and removes o, leaving its path untouched.
+func (o *pendingOutput) discard() {
+	if o.f == nil {
+		return
+	}
+
+	o.f.Close()
+	os.Remove(o.f.Name())
+}
+
+// readPublicKey reads a PEM PublicKey from path.
+func readPublicKey(path string) (knapsack.PublicKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return knapsack.ReadPublicKeyPEM(f)
+}
+
+// readCryptosystemPublicKey reads a PEM public key of any scheme from path.
+func readCryptosystemPublicKey(path string) (knapsack.Cryptosystem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return knapsack.ReadCryptosystemPublicKeyPEM(f)
+}
+
+// readPrivateKey reads a PEM key pair of any scheme from path, which checks that it's valid.
+// If passphraseFile isn't empty, the key is expected to be encrypted with the passphrase in it.
+func readPrivateKey(path, passphraseFile string) (knapsack.Cryptosystem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c knapsack.Cryptosystem
+	if passphraseFile == "" {
+		c, err = knapsack.ReadCryptosystemPrivateKeyPEM(bytes.NewReader(data))
+	} else {
+		var passphrase string
+		passphrase, err = readPassphrase(passphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		c, err = knapsack.ReadEncryptedCryptosystemPrivateKeyPEM(bytes.NewReader(data), passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// readPassphrase reads the first line of path.
+func readPassphrase(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	passphrase, _, _ := strings.Cut(string(data), "\n")
+	passphrase = strings.TrimSuffix(passphrase, "\r")
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase file %s is empty", path)
+	}
+
+	return passphrase, nil
+}
+
+// readAll reads all of path, or stdin if path is stdio.
+func readAll(path string, stdin io.Reader) ([]byte, error) {
+	r, err := openInput(path, stdin)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
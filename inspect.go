@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"io"
+
+	"github.com/chronotrax/knapsack/knapsack"
+)
+
+func inspect(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("inspect", "[-in file]",
+		"Prints every key in a PEM key file with its density and the lattice attacks it is expected\nto fall to (see knapsack.Analyze), or the header of a ciphertext container.", stderr)
+	in := fs.String("in", stdio, "key or ciphertext file, - for stdin")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	data, err := readAll(*in, stdin)
+	if err != nil {
+		return err
+	}
+
+	// ciphertext containers are binary, not PEM
+	if h, err := knapsack.ReadContainerHeader(bytes.NewReader(data)); err == nil {
+		fmt.Fprintln(stdout, "ciphertext container")
+		fmt.Fprintln(stdout, "block size (in bits):", h.Bits)
+		fmt.Fprintln(stdout, "blocks:", h.Blocks)
+		return nil
+	}
+
+	found := false
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		found = true
+
+		if err := inspectBlock(stdout, block); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout)
+	}
+
+	if !found {
+		return fmt.Errorf("no PEM blocks or ciphertext container found in %s", *in)
+	}
+
+	return nil
+}
+
+func inspectBlock(w io.Writer, block *pem.Block) error {
+	r := bytes.NewReader(pem.EncodeToMemory(block))
+
+	scheme, ok := block.Headers["Scheme"]
+	if ok && scheme != knapsack.SchemeMerkleHellman && block.Type != "ENCRYPTED KNAPSACK PRIVATE KEY" {
+		return inspectCryptosystem(w, block.Type, r)
+	}
+
+	switch block.Type {
+	case "KNAPSACK PUBLIC KEY":
+		public, err := knapsack.ReadPublicKeyPEM(r)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(w, "public key")
+		fmt.Fprintln(w, "block size (in bits):", public.Bits())
+		fmt.Fprintln(w, "values:", knapsack.BigIntsToStr(public))
+		printAnalysis(w, knapsack.Analyze(public))
+	case "KNAPSACK PRIVATE KEY":
+		private, err := knapsack.ReadPrivateKeyPEM(r)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(w, "private key")
+		fmt.Fprintln(w, "block size (in bits):", private.Bits())
+		fmt.Fprintf(w, "v=%d, u=%d\n", private.V, private.U)
+		fmt.Fprintln(w, "set:", knapsack.BigIntsToStr(private.Set))
+		if private.Perm != nil {
+			fmt.Fprintln(w, "perm:", private.Perm)
+		} else {
+			fmt.Fprintln(w, "perm: none, the public key is in the set's order")
+		}
+		for i, r := range private.Rounds {
+			fmt.Fprintf(w, "round %d: v=%d, u=%d\n", i+1, r.V, r.U)
+		}
+		fmt.Fprintln(w, "superincreasing:", private.Set.IsSuperincreasing())
+		if err := private.Validate(); err != nil {
+			fmt.Fprintln(w, "valid: false,", err)
+		} else {
+			fmt.Fprintln(w, "valid: true")
+		}
+		printAnalysis(w, knapsack.Analyze(knapsack.NewPublicKey(private, private.Set)))
+	case "ENCRYPTED KNAPSACK PRIVATE KEY":
+		fmt.Fprintln(w, "encrypted private key")
+		if ok {
+			fmt.Fprintln(w, "scheme:", scheme)
+		}
+		if bits, ok := block.Headers["Bits"]; ok {
+			fmt.Fprintln(w, "block size (in bits):", bits)
+		} else {
+			fmt.Fprintln(w, "block size (in bytes):", block.Headers["Block-Size"])
+		}
+	default:
+		return fmt.Errorf("unknown PEM block %q", block.Type)
+	}
+
+	return nil
+}
+
+// inspectCryptosystem prints a key of a scheme other than Merkle–Hellman, read from the PEM block of typ in r.
+func inspectCryptosystem(w io.Writer, typ string, r io.Reader) error {
+	var c knapsack.Cryptosystem
+	var err error
+	switch typ {
+	case "KNAPSACK PUBLIC KEY":
+		c, err = knapsack.ReadCryptosystemPublicKeyPEM(r)
+		fmt.Fprintln(w, "public key")
+	case "KNAPSACK PRIVATE KEY":
+		// reading the key pair checks that it's valid
+		c, err = knapsack.ReadCryptosystemPrivateKeyPEM(r)
+		fmt.Fprintln(w, "private key")
+	default:
+		return fmt.Errorf("unknown PEM block %q", typ)
+	}
+	if err != nil {
+		return err
+	}
+
+	params := c.PublicParams()
+	fmt.Fprintln(w, "scheme:", params.Scheme)
+	fmt.Fprintln(w, "block size (in bits):", params.Bits)
+	fmt.Fprintln(w, "values:", knapsack.BigIntsToStr(params.Values))
+	fmt.Fprintln(w, "modulus:", params.Modulus)
+
+	// the lattice attacks only apply to sums
+	if !params.Multiplicative {
+		printDensity(w, knapsack.Analyze(params.Values))
+	}
+
+	return nil
+}
+
+// printAnalysis prints the density report of a public key.
+func printAnalysis(w io.Writer, a knapsack.Analysis) {
+	printDensity(w, a)
+	fmt.Fprintln(w, "Shamir's attack: feasible, for any density, unless the key has extra rounds")
+}
+
+// printDensity prints the density of a knapsack and the low density attacks it is expected to fall to.
+func printDensity(w io.Writer, a knapsack.Analysis) {
+	fmt.Fprintf(w, "bit lengths: min %d, max %d\n", a.MinBits, a.MaxBits)
+	fmt.Fprintf(w, "density: %.4f (n=%d / log2(max)=%.2f)\n", a.Density, a.N, a.Log2Max)
+	fmt.Fprintf(w, "Lagarias–Odlyzko attack (density < %v): %s\n", knapsack.LagariasOdlyzkoDensity, feasible(a.LagariasOdlyzko))
+	fmt.Fprintf(w, "CJLOSS attack (density < %v): %s\n", knapsack.CJLOSSDensity, feasible(a.CJLOSS))
+}
+
+func feasible(b bool) string {
+	if b {
+		return "feasible"
+	}
+	return "not expected to succeed"
+}
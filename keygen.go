@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chronotrax/knapsack/knapsack"
+)
+
+func keygen(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("keygen", "[-scheme s] [-block-size n | -bits n] [-generator g] [-max-gap n] [-rounds n] [-seed s] [-v v -u u -set s1,s2,... [-perm p1,p2,...]] [-public file] [-private file]",
+		"Generates a random key pair, or builds one from a custom v, u and superincreasing set,\n"+
+			"and writes the public and private keys to separate PEM files (see \"go doc .\" for the format).", stderr)
+	scheme := fs.String("scheme", knapsack.SchemeMerkleHellman, "cryptosystem of the key pair: "+strings.Join(knapsack.Schemes(), ", ")+
+		"\n(only merkle-hellman takes -generator, -max-gap, -rounds or a custom key)")
+	blockSize := fs.Int("block-size", 1, "block size in bytes for a random key")
+	bits := fs.Int("bits", 0, "block size in bits for a random key, overrides -block-size")
+	generator := fs.String("generator", "gap", "random set generator: gap, bits:b, classic or density:d (see setGenerator)")
+	maxGap := fs.String("max-gap", "8", "with -generator gap, each random set value is the sum of the previous values plus [2, max-gap+2)")
+	rounds := fs.Int("rounds", 0, "extra modular multiplications of a random key, for the iterated Merkle–Hellman scheme")
+	seed := fs.String("seed", "", "derive a random key deterministically from this seed instead of crypto/rand (see knapsack.NewSeededRand)")
+	vStr := fs.String("v", "", "custom private multiplier v")
+	uStr := fs.String("u", "", "custom private modulus u")
+	setStr := fs.String("set", "", "custom superincreasing set, 8 values per byte of block size")
+	permStr := fs.String("perm", "", "custom permutation, public value i is built from set value perm[i] (default: the set's order)")
+	publicPath := fs.String("public", "knapsack.pub", "file to write the public key to, - for stdout")
+	privatePath := fs.String("private", "knapsack.key", "file to write the private key to, - for stdout")
+	passphraseFile := fs.String("passphrase-file", "", "encrypt the private key with the passphrase in this file")
+	force := fs.Bool("force", false, "overwrite existing key files")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	// read before generating anything, so a bad passphrase file fails fast
+	var passphrase string
+	if *passphraseFile != "" {
+		var err error
+		passphrase, err = readPassphrase(*passphraseFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	var c knapsack.Cryptosystem
+	var err error
+	switch {
+	case *scheme != knapsack.SchemeMerkleHellman:
+		if err := exclusive(fs, "scheme", "generator", "max-gap", "rounds", "v", "u", "set", "perm"); err != nil {
+			return err
+		}
+
+		c, err = knapsack.NewCryptosystem(*scheme)
+		if err != nil {
+			return err
+		}
+
+		n := *bits
+		if n == 0 {
+			n = 8 * *blockSize
+		}
+
+		var random io.Reader
+		if *seed != "" {
+			random = knapsack.NewSeededRand([]byte(*seed))
+		}
+		err = c.GenerateKey(random, n)
+	case *vStr == "" && *uStr == "" && *setStr == "" && *permStr == "":
+		// only the gap generator has a gap to bound
+		if *generator != "gap" {
+			if err := exclusive(fs, "generator", "max-gap"); err != nil {
+				return err
+			}
+		}
+
+		gap, success := new(big.Int).SetString(*maxGap, 10)
+		if !success {
+			return fmt.Errorf("max-gap is not an integer: %q", *maxGap)
+		}
+
+		var gen knapsack.SetGenerator
+		gen, err = setGenerator(*generator)
+		if err != nil {
+			return err
+		}
+
+		opts := knapsack.Options{MaxGap: gap, Set: gen, Rounds: *rounds}
+		if *seed != "" {
+			opts.Rand = knapsack.NewSeededRand([]byte(*seed))
+		}
+
+		if *bits != 0 {
+			c, err = knapsack.NewKnapsackBits(*bits, opts)
+		} else {
+			c, err = knapsack.NewKnapsackWithOptions(*blockSize, opts)
+		}
+	default:
+		if err := required(fs, "v", "u", "set"); err != nil {
+			return err
+		}
+		// a custom key uses no randomness, so the random key flags would be silently ignored
+		if err := exclusive(fs, "set", "block-size", "bits", "generator", "max-gap", "rounds", "seed"); err != nil {
+			return err
+		}
+		c, err = customKnapsack(*vStr, *uStr, *setStr, *permStr)
+	}
+	if err != nil {
+		return err
+	}
+
+	// create both files before writing either, so an existing private key file doesn't leave a new public key behind
+	public, err := createKeyFile(*publicPath, 0o644, *force, stdout)
+	if err != nil {
+		return err
+	}
+	private, err := createKeyFile(*privatePath, 0o600, *force, stdout)
+	if err != nil {
+		public.close(true)
+		return err
+	}
+
+	err = knapsack.WriteCryptosystemPublicKeyPEM(public, c)
+	if err == nil {
+		if passphrase == "" {
+			err = knapsack.WriteCryptosystemPrivateKeyPEM(private, c)
+		} else {
+			err = knapsack.WriteEncryptedCryptosystemPrivateKeyPEM(private, c, passphrase)
+		}
+	}
+
+	// a failed write removes both files, never just one
+	failed := err != nil
+	if closeErr := public.close(failed); err == nil {
+		err = closeErr
+	}
+	if closeErr := private.close(failed); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// keyFile is a key file created by createKeyFile, or stdout.
+type keyFile struct {
+	io.Writer
+	f *os.File // nil for stdout
+}
+
+// createKeyFile creates path with perm, or returns stdout if path is stdio.
+// Existing files are only replaced if force is set.
+func createKeyFile(path string, perm os.FileMode, force bool, stdout io.Writer) (*keyFile, error) {
+	if path == stdio {
+		return &keyFile{Writer: stdout}, nil
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(path, flag, perm)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("%s already exists, use -force to overwrite it", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyFile{Writer: f, f: f}, nil
+}
+
+// close closes k, and removes it if writing the keys failed.
+func (k *keyFile) close(failed bool) error {
+	if k.f == nil {
+		return nil
+	}
+
+	err := k.f.Close()
+	if failed {
+		return os.Remove(k.f.Name())
+	}
+
+	return err
+}
+
+// setGenerator parses a -generator flag:
+//
+//	gap        the sum of the previous values plus a gap bounded by -max-gap
+//	bits:b     Set[i] has at most b+i bits
+//	classic    Set[i] is between [(2^i - 1)*2^n + 1, 2^i*2^n], as in Merkle and Hellman's paper
+//	density:d  a public key with a density of about d
+//
+// gap returns a nil knapsack.SetGenerator, so -max-gap is used.
+func setGenerator(spec string) (knapsack.SetGenerator, error) {
+	name, arg, hasArg := strings.Cut(spec, ":")
+
+	switch {
+	case name == "gap" && !hasArg:
+		return nil, nil
+	case name == "classic" && !hasArg:
+		return knapsack.ClassicGenerator{}, nil
+	case name == "bits" && hasArg:
+		bits, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("generator bits is not an integer: %q", arg)
+		}
+		return knapsack.BitLengthGenerator{Bits: bits}, nil
+	case name == "density" && hasArg:
+		density, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("generator density is not a number: %q", arg)
+		}
+		return knapsack.DensityGenerator{Density: density}, nil
+	default:
+		return nil, fmt.Errorf("unknown generator %q, want gap, bits:b, classic or density:d", spec)
+	}
+}
+
+// customKnapsack builds a Knapsack from decimal v, u, a comma separated set and an optional comma separated perm.
+func customKnapsack(vStr, uStr, setStr, permStr string) (*knapsack.Knapsack, error) {
+	v, success := new(big.Int).SetString(vStr, 10)
+	if !success {
+		return nil, fmt.Errorf("v is not an integer: %q", vStr)
+	}
+
+	u, success := new(big.Int).SetString(uStr, 10)
+	if !success {
+		return nil, fmt.Errorf("u is not an integer: %q", uStr)
+	}
+
+	s := make(knapsack.Set, 0)
+	for _, sStr := range strings.Split(setStr, ",") {
+		si, success := new(big.Int).SetString(strings.TrimSpace(sStr), 10)
+		if !success {
+			return nil, fmt.Errorf("invalid set value: %q", sStr)
+		}
+		s = append(s, si)
+	}
+
+	var perm []int
+	if permStr != "" {
+		for _, pStr := range strings.Split(permStr, ",") {
+			p, err := strconv.Atoi(strings.TrimSpace(pStr))
+			if err != nil {
+				return nil, fmt.Errorf("invalid perm value: %q", pStr)
+			}
+			perm = append(perm, p)
+		}
+	}
+
+	if len(s)%8 != 0 {
+		return nil, fmt.Errorf("set has %d values, must be a multiple of 8", len(s))
+	}
+
+	return knapsack.NewKnapsackCustom(len(s)/8, &knapsack.PrivateKey{V: v, U: u, Perm: perm}, s)
+}
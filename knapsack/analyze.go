@@ -0,0 +1,84 @@
+package knapsack
+
+import (
+	"math"
+	"math/big"
+)
+
+// Densities below which lattice reduction is expected to recover a plaintext from its ciphertext,
+// given a perfect shortest vector oracle.
+const (
+	// LagariasOdlyzkoDensity is the bound of Lagarias and Odlyzko's low density attack.
+	LagariasOdlyzkoDensity = 0.6463
+
+	// CJLOSSDensity is the bound of the improved attack by Coster, Joux, LaMacchia, Odlyzko, Schnorr and Stern.
+	CJLOSSDensity = 0.9408
+)
+
+// Analysis describes how attackable a PublicKey is.
+type Analysis struct {
+	// N is the number of values, the bits encrypted per block.
+	N int
+
+	// Bits holds the bit length of each value.
+	Bits []int
+
+	// MinBits and MaxBits are the smallest and largest bit lengths.
+	MinBits, MaxBits int
+
+	// Log2Max is log2 of the largest value.
+	Log2Max float64
+
+	// Density is N / log2(max Public[i]).
+	Density float64
+
+	// LagariasOdlyzko and CJLOSS report whether Density is below LagariasOdlyzkoDensity and CJLOSSDensity,
+	// so the low density attacks are expected to succeed.
+	LagariasOdlyzko bool
+	CJLOSS          bool
+}
+
+// log2 returns log2(x) for x > 0, accurate even when x doesn't fit in a float64.
+func log2(x *big.Int) float64 {
+	mant := new(big.Float)
+	exp := new(big.Float).SetInt(x).MantExp(mant)
+
+	// x = mant * 2^exp, with mant between [0.5, 1)
+	m, _ := mant.Float64()
+	return float64(exp) + math.Log2(m)
+}
+
+// Analyze measures public's density and compares it with the thresholds of the known lattice attacks.
+// The basic Merkle–Hellman scheme is broken by Shamir's attack regardless of its density.
+func Analyze(public PublicKey) Analysis {
+	a := Analysis{
+		N:    len(public),
+		Bits: make([]int, len(public)),
+	}
+
+	largest := big.NewInt(0)
+	for i, p := range public {
+		a.Bits[i] = p.BitLen()
+
+		if i == 0 || a.Bits[i] < a.MinBits {
+			a.MinBits = a.Bits[i]
+		}
+		a.MaxBits = max(a.MaxBits, a.Bits[i])
+
+		if p.Cmp(largest) == 1 {
+			largest = p
+		}
+	}
+
+	// a key of 0's and 1's carries no information, treat it as infinitely dense
+	a.Density = math.Inf(1)
+	if largest.Cmp(big.NewInt(1)) == 1 {
+		a.Log2Max = log2(largest)
+		a.Density = float64(a.N) / a.Log2Max
+	}
+
+	a.LagariasOdlyzko = a.Density < LagariasOdlyzkoDensity
+	a.CJLOSS = a.Density < CJLOSSDensity
+
+	return a
+}
@@ -0,0 +1,54 @@
+package knapsack
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	t.Run("fixture", func(t *testing.T) {
+		private := &PrivateKey{Set: bigInts(3, 5, 9, 18, 38, 75, 155, 310), V: big.NewInt(13), U: big.NewInt(672)}
+		a := Analyze(NewPublicKey(private, private.Set))
+
+		// the largest public value is 13*155 % 672 = 671
+		want := 8 / math.Log2(671)
+		if a.N != 8 || a.MinBits != 6 || a.MaxBits != 10 || math.Abs(a.Density-want) > 1e-9 {
+			t.Errorf("got %+v, want N=8 MinBits=6 MaxBits=10 Density=%v", a, want)
+		}
+		if a.LagariasOdlyzko || !a.CJLOSS {
+			t.Errorf("got LagariasOdlyzko=%v CJLOSS=%v, want false and true", a.LagariasOdlyzko, a.CJLOSS)
+		}
+	})
+
+	t.Run("large values", func(t *testing.T) {
+		// log2 has to work past the range of a float64
+		public := PublicKey{new(big.Int).Lsh(big.NewInt(1), 2000), big.NewInt(3)}
+		a := Analyze(public)
+
+		if a.Log2Max != 2000 || a.Density != 2.0/2000 || a.MaxBits != 2001 || a.MinBits != 2 {
+			t.Errorf("got %+v", a)
+		}
+		if !a.LagariasOdlyzko || !a.CJLOSS {
+			t.Errorf("got LagariasOdlyzko=%v CJLOSS=%v, want true", a.LagariasOdlyzko, a.CJLOSS)
+		}
+	})
+
+	t.Run("classic", func(t *testing.T) {
+		// the original parameters give a density of about 1/2
+		k, err := NewKnapsackBits(100, Options{Rand: NewSeededRand([]byte("classic")), Set: ClassicGenerator{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if a := Analyze(k.Public); a.Density < 0.45 || a.Density > 0.5 || !a.LagariasOdlyzko {
+			t.Errorf("got %+v", a)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if a := Analyze(PublicKey{}); !math.IsInf(a.Density, 1) || a.CJLOSS {
+			t.Errorf("got %+v", a)
+		}
+	})
+}
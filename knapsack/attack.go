@@ -3,121 +3,198 @@ package knapsack
 import (
 	"fmt"
 	"github.com/chronotrax/knapsack/matrix"
+	"log"
 	"math/big"
-	"slices"
+	"sync"
+	"time"
 )
 
-// gs is the Gram–Schmidt algorithm.
-func gs(b matrix.Matrix) (x, y matrix.Matrix) {
-	n := b.Height()
-	x = matrix.NewMatrixEmpty(n, n)
-	y = matrix.NewMatrixEmpty(n, n)
+// ReductionStats describes one run of lll.
+type ReductionStats struct {
+	// Dimension is the number of basis vectors.
+	Dimension int
 
-	// x0 = b0 (b0 is M's first column, not row)
-	x.SetCol(0, b.Col(0))
+	// Iterations is the number of steps lll took, each either swapping two basis vectors or moving on to the next.
+	Iterations int
 
-	// for j = 1 to n
-	for j := 1; j < n; j++ {
-		// xj = bj
-		x.SetCol(j, b.Col(j))
+	// SizeReductions and Swaps count the basis vectors lll subtracted and swapped.
+	SizeReductions int
+	Swaps          int
 
-		// for i = 0 to j - 1 (inclusive)
-		for i := 0; i <= j-1; i++ {
-			// xi * bj
-			prod := new(big.Rat).Set(matrix.DotProduct(x.Col(i), b.Col(j)))
+	// Reduced reports whether lll finished, leaving a basis that IsLLLReduced.
+	// It is false if lll ran out of iterations, or stopped at a linearly dependent basis vector.
+	Reduced bool
+}
 
-			// yij = (xi * bj) / ||xi||^2
-			co := new(big.Rat).Quo(prod, matrix.DotProduct(x.Col(i), x.Col(i)))
-			// we must keep track of coefficients for LLL
-			y[j][i] = co
+// gramSchmidt holds the Gram–Schmidt coefficients lll keeps up to date instead of recomputing them per step:
+// mu[k][j] = <b_k, b*_j> / B[j] for j < k, and B[k] = ||b*_k||^2, where b* is the orthogonalized basis.
+type gramSchmidt struct {
+	mu [][]*big.Rat
+	B  []*big.Rat
+}
 
-			// xj = xj - yij * xi
-			x.SetCol(j, matrix.SubtractVec(x.Col(j), matrix.MulRatOnVec(co, x.Col(i))))
-		}
+func newGramSchmidt(n int) *gramSchmidt {
+	g := &gramSchmidt{mu: make([][]*big.Rat, n), B: make([]*big.Rat, n)}
+	for k := range g.mu {
+		g.mu[k] = make([]*big.Rat, k)
 	}
 
-	return x, y
+	return g
 }
 
-// lll is the Lenstra–Lenstra–Lovász lattice basis reduction algorithm
-func lll(b matrix.Matrix, delta *big.Rat, maxIterations int) matrix.Matrix {
-	// matrix is n by n square
-	n := b.Height()
+// orthogonalize computes mu[k] and B[k] from the basis vectors b_0 to b_k,
+// given mu and B of every earlier vector. It reports whether b_k is linearly independent of them.
+func (g *gramSchmidt) orthogonalize(b matrix.Matrix, k int) bool {
+	bk := b.Col(k)
 
-	// (X,Y) = GS(M)
-	x, y := gs(b)
-	//fmt.Printf("x after first GS:\n%s\n\n", x)
-	//fmt.Printf("y after first GS:\n%s\n\n", y)
+	// mu[k][j] = (<b_k, b_j> - sum over i < j of mu[j][i] * mu[k][i] * B[i]) / B[j]
+	for j := 0; j < k; j++ {
+		sum := new(big.Rat).Set(matrix.DotProduct(bk, b.Col(j)))
+		for i := 0; i < j; i++ {
+			sum.Sub(sum, new(big.Rat).Mul(new(big.Rat).Mul(g.mu[j][i], g.mu[k][i]), g.B[i]))
+		}
+		g.mu[k][j] = sum.Quo(sum, g.B[j])
+	}
 
-	// since we cannot run forever, go until maxIterations
-	for iter := 1; iter <= maxIterations; iter++ {
-		//fmt.Println("ITERATION", iter)
+	// B[k] = ||b_k||^2 - sum over j < k of mu[k][j]^2 * B[j]
+	bb := new(big.Rat).Set(matrix.DotProduct(bk, bk))
+	for j := 0; j < k; j++ {
+		bb.Sub(bb, new(big.Rat).Mul(new(big.Rat).Mul(g.mu[k][j], g.mu[k][j]), g.B[j]))
+	}
+	g.B[k] = bb
 
-		// for j = 1 to n
-		for j := 1; j < n; j++ {
+	return bb.Sign() != 0
+}
 
-			// for i = j - 1 to 0
-			for i := j - 1; i >= 0; i-- {
-				// |yij|
-				abs := new(big.Rat).Abs(y[j][i])
+// lovasz reports whether B[k] >= (delta - mu[k][k-1]^2) * B[k-1], the Lovász condition between b_k-1 and b_k.
+func (g *gramSchmidt) lovasz(k int, delta *big.Rat) bool {
+	mu := g.mu[k][k-1]
+	right := new(big.Rat).Sub(delta, new(big.Rat).Mul(mu, mu))
+	right.Mul(right, g.B[k-1])
 
-				// if |yij| > 1/2
-				if abs.Cmp(big.NewRat(1, 2)) > 0 {
-					// yij + 1/2
-					sum := new(big.Rat).Add(y[j][i], big.NewRat(1, 2))
+	return g.B[k].Cmp(right) >= 0
+}
 
-					// floor(yij + 1/2)
-					flo := new(big.Int).Quo(sum.Num(), sum.Denom())
+// IsLLLReduced reports whether the columns of basis are an LLL reduced basis for delta:
+// linearly independent, size reduced with every |mu[k][j]| <= 1/2,
+// and satisfying the Lovász condition between every pair of consecutive vectors.
+func IsLLLReduced(basis matrix.Matrix, delta *big.Rat) bool {
+	n := basis.Width()
+	g := newGramSchmidt(n)
+	half := big.NewRat(1, 2)
 
-					// bj - floor(yij + 1/2) * bi
-					dif := matrix.SubtractVec(b.Col(j), matrix.MulRatOnVec(new(big.Rat).SetInt(flo), b.Col(i)))
+	for k := 0; k < n; k++ {
+		if !g.orthogonalize(basis, k) {
+			return false
+		}
 
-					// bj = bj - floor(yij + 1/2) * bi
-					b.SetCol(j, dif)
-				}
+		for j := 0; j < k; j++ {
+			if new(big.Rat).Abs(g.mu[k][j]).Cmp(half) > 0 {
+				return false
 			}
 		}
 
-		//fmt.Printf("x after first half of LLL:\n%s\n\n", x)
-		//fmt.Printf("y after first half of LLL:\n%s\n\n", y)
+		if k > 0 && !g.lovasz(k, delta) {
+			return false
+		}
+	}
 
-		// (X,Y) = GS(M)
-		x, y = gs(b)
-		//fmt.Printf("x after second GS:\n%s\n\n", x)
-		//fmt.Printf("y after second GS:\n%s\n\n", y)
+	return true
+}
 
-		// for j = 0 to n - 1
-		for j := 0; j < n-1; j++ {
-			// 3/4 * ||xj||^2
-			right := new(big.Rat).Mul(delta, matrix.DotProduct(x.Col(j), x.Col(j)))
+// lll is the Lenstra–Lenstra–Lovász lattice basis reduction algorithm, reducing the columns of b in place.
+// It follows Cohen's Algorithm 2.6.3 in exact rational arithmetic, updating the Gram–Schmidt coefficients
+// after every size reduction and swap rather than recomputing them from scratch.
+// For a linearly independent basis and delta between (1/4, 1) it always terminates, delta = 1 may take exponentially long,
+// so maxIterations bounds its steps, unlimited if 0.
+func lll(b matrix.Matrix, delta *big.Rat, maxIterations int) (matrix.Matrix, ReductionStats) {
+	n := b.Width()
+	stats := ReductionStats{Dimension: n}
+
+	g := newGramSchmidt(n)
+	if !g.orthogonalize(b, 0) {
+		return b, stats
+	}
 
-			// yj,j+1 * xj
-			prod := matrix.MulRatOnVec(y[j+1][j], x.Col(j))
+	// b_0 to b_k-1 are LLL reduced, mu and B are known up to b_kmax
+	k, kmax := 1, 0
+	for k < n {
+		if maxIterations > 0 && stats.Iterations >= maxIterations {
+			return b, stats
+		}
+		stats.Iterations++
 
-			// xj+1 + yj,j+1 * xj
-			sum := make(matrix.Vector, n)
-			for i := 0; i < len(prod); i++ {
-				sum[i] = new(big.Rat).Add(prod[i], x[i][j+1])
+		if k > kmax {
+			kmax = k
+			if !g.orthogonalize(b, k) {
+				return b, stats
 			}
+		}
 
-			// ||xj+1 + yj,j+1 * xj||^2
-			left := matrix.DotProduct(sum, sum)
+		lllReduce(b, g, k, k-1, &stats)
 
-			// if ||xj+1 + yj,j+1 * xj||^2 < 3/4 * ||xj||^2
-			if left.Cmp(right) < 0 {
-				// swap(bj, bj+1)
-				temp := b.Col(j)
-				b.SetCol(j, b.Col(j+1))
-				b.SetCol(j+1, temp)
-				break
-			}
+		if !g.lovasz(k, delta) {
+			lllSwap(b, g, k, kmax, &stats)
+			k = max(1, k-1)
+			continue
+		}
+
+		for l := k - 2; l >= 0; l-- {
+			lllReduce(b, g, k, l, &stats)
 		}
+		k++
+	}
+
+	stats.Reduced = true
+	return b, stats
+}
+
+// lllReduce size reduces b_k by b_l, for l < k: b_k = b_k - round(mu[k][l]) * b_l, so |mu[k][l]| <= 1/2.
+func lllReduce(b matrix.Matrix, g *gramSchmidt, k, l int, stats *ReductionStats) {
+	half := big.NewRat(1, 2)
+	if new(big.Rat).Abs(g.mu[k][l]).Cmp(half) <= 0 {
+		return
+	}
+
+	// q = floor(mu[k][l] + 1/2)
+	sum := new(big.Rat).Add(g.mu[k][l], half)
+	q := new(big.Rat).SetInt(new(big.Int).Div(sum.Num(), sum.Denom()))
 
-		//fmt.Printf("x after second half of LLL:\n%s\n\n", x)
-		//fmt.Printf("y after second half of LLL:\n%s\n\n", y)
+	b.SetCol(k, matrix.SubtractVec(b.Col(k), matrix.MulRatOnVec(q, b.Col(l))))
+
+	// b*_k is unchanged, only the coefficients of b_k on b*_l and the vectors before it move
+	g.mu[k][l] = new(big.Rat).Sub(g.mu[k][l], q)
+	for i := 0; i < l; i++ {
+		g.mu[k][i] = new(big.Rat).Sub(g.mu[k][i], new(big.Rat).Mul(q, g.mu[l][i]))
 	}
 
-	return b
+	stats.SizeReductions++
+}
+
+// lllSwap swaps b_k-1 and b_k, updating mu and B of every vector up to b_kmax.
+func lllSwap(b matrix.Matrix, g *gramSchmidt, k, kmax int, stats *ReductionStats) {
+	temp := b.Col(k)
+	b.SetCol(k, b.Col(k-1))
+	b.SetCol(k-1, temp)
+
+	for j := 0; j < k-1; j++ {
+		g.mu[k][j], g.mu[k-1][j] = g.mu[k-1][j], g.mu[k][j]
+	}
+
+	// the new b*_k-1 is the old b*_k + mu * b*_k-1
+	mu := g.mu[k][k-1]
+	bb := new(big.Rat).Add(g.B[k], new(big.Rat).Mul(new(big.Rat).Mul(mu, mu), g.B[k-1]))
+	g.mu[k][k-1] = new(big.Rat).Quo(new(big.Rat).Mul(mu, g.B[k-1]), bb)
+	g.B[k] = new(big.Rat).Quo(new(big.Rat).Mul(g.B[k-1], g.B[k]), bb)
+	g.B[k-1] = bb
+
+	for i := k + 1; i <= kmax; i++ {
+		t := g.mu[i][k]
+		g.mu[i][k] = new(big.Rat).Sub(g.mu[i][k-1], new(big.Rat).Mul(mu, t))
+		g.mu[i][k-1] = new(big.Rat).Add(t, new(big.Rat).Mul(g.mu[k][k-1], g.mu[i][k]))
+	}
+
+	stats.Swaps++
 }
 
 // checkColumn checks if the c column of Matrix m is in the correct form:
@@ -140,18 +217,52 @@ func checkColumn(c matrix.Vector) bool {
 	return true
 }
 
-func Attack(blockSize int, cipher Ciphertext, public PublicKey, expected []byte) {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Println("Attack had an panic:", r)
-		}
-	}()
+// Lattice selects the lattice basis Attack reduces.
+type Lattice int
 
-	k := &Knapsack{
-		BlockSize: blockSize,
-		Public:    public,
+const (
+	// LagariasOdlyzkoLattice is the identity matrix on top of the public key, with -c in the bottom right corner.
+	// The plaintext bits are a short vector of it, found by LLL for densities below LagariasOdlyzkoDensity.
+	LagariasOdlyzkoLattice Lattice = iota
+
+	// CJLOSSLattice is the improved lattice of Coster, Joux, LaMacchia, Odlyzko, Schnorr and Stern:
+	// the identity matrix on top of the public key, next to a column of 1/2 over c.
+	// The plaintext bits shifted to ±1/2 are a short vector of it, about half as long as the 0/1 one,
+	// found by LLL for densities below CJLOSSDensity.
+	CJLOSSLattice
+)
+
+func (l Lattice) String() string {
+	switch l {
+	case LagariasOdlyzkoLattice:
+		return "lagarias-odlyzko"
+	case CJLOSSLattice:
+		return "cjloss"
+	default:
+		return fmt.Sprintf("Lattice(%d)", int(l))
 	}
+}
+
+// defaultScale returns the scaling factor N Attack uses when AttackOptions.Scale is nil:
+// 1 for the LagariasOdlyzkoLattice, and ceil(sqrt(n)) for the CJLOSSLattice of n public values,
+// above the sqrt(n)/2 its short vectors need.
+func (l Lattice) defaultScale(n int) *big.Int {
+	if l != CJLOSSLattice {
+		return big.NewInt(1)
+	}
+
+	s := new(big.Int).Sqrt(big.NewInt(int64(n)))
+	if new(big.Int).Mul(s, s).Cmp(big.NewInt(int64(n))) != 0 {
+		s.Add(s, big.NewInt(1))
+	}
+
+	return s
+}
 
+// publicBasis builds the part of the lattice shared by every ciphertext block:
+// an identity matrix on top of a bottom row of public scaled by scale, with a 0 in the bottom right corner.
+// The CJLOSSLattice fills the rest of the last column with 1/2.
+func publicBasis(public PublicKey, lattice Lattice, scale *big.Int) matrix.Matrix {
 	// size is 1 larger than the original block size
 	size := len(public) + 1
 	m := matrix.NewMatrixEmpty(size, size)
@@ -161,42 +272,326 @@ func Attack(blockSize int, cipher Ciphertext, public PublicKey, expected []byte)
 		m[i][i] = big.NewRat(1, 1)
 	}
 
-	// make bottom row (0 to n-1) the public key
+	// make bottom row (0 to n-1) the scaled public key
 	for i := 0; i < size-1; i++ {
-		m[size-1][i] = new(big.Rat).SetInt(public[i])
+		m[size-1][i] = new(big.Rat).SetInt(new(big.Int).Mul(scale, public[i]))
 	}
 
-	// make bottom right corner -1*cipher
-	m[size-1][size-1] = new(big.Rat).Mul(new(big.Rat).SetInt(cipher[0]), big.NewRat(-1, 1))
+	if lattice == CJLOSSLattice {
+		for i := 0; i < size-1; i++ {
+			m[i][size-1] = big.NewRat(1, 2)
+		}
+	}
 
-	fmt.Printf("initial matrix:\n%s\n\n", m)
+	return m
+}
 
-	reduced := lll(m, big.NewRat(3, 4), 1000)
+// blockBasis returns a copy of a publicBasis with the scaled ciphertext block c in the bottom right corner,
+// negated for the LagariasOdlyzkoLattice, the lattice whose short vectors reveal the plaintext of c.
+func blockBasis(basis matrix.Matrix, c *big.Int, lattice Lattice, scale *big.Int) matrix.Matrix {
+	m := basis.Copy()
+	size := m.Height()
 
-	fmt.Printf("reduced matrix:\n%s\n\n", reduced)
+	corner := new(big.Int).Mul(scale, c)
+	if lattice == LagariasOdlyzkoLattice {
+		// make bottom right corner -1*cipher
+		corner.Neg(corner)
+	}
+	m[size-1][size-1] = new(big.Rat).SetInt(corner)
 
-	found := false
-	for i := 0; i < size; i++ {
-		col := reduced.Col(i)
-		if checkColumn(col) {
-			found = true
-			fmt.Printf("suspected plaintext found at column %d: %v\n", i, col)
+	return m
+}
 
-			plain := make(Plaintext, len(public))
-			for j := 0; j < len(public); j++ {
-				plain[j] = new(big.Int).Set(col[j].Num())
-			}
+// checkHalfColumn checks if the column c of a reduced CJLOSSLattice is in the correct form:
+// 0 to n-2 are all 1/2's or -1/2's, and n-1 is a 0.
+func checkHalfColumn(c matrix.Vector) bool {
+	half := big.NewRat(1, 2)
 
-			data := k.FromPlaintext(plain)
-			if slices.Equal(data, expected) {
-				fmt.Println("suspected plaintext matches original! :D")
-			} else {
-				fmt.Println("but it does NOT match the original plaintext :(")
-			}
+	// 0 to n-2 must be a 1/2 or -1/2
+	for i := 0; i < len(c)-1; i++ {
+		if new(big.Rat).Abs(c[i]).Cmp(half) != 0 {
+			return false
 		}
 	}
 
-	if !found {
-		fmt.Println("no suspected plaintext found in reduced matrix :(")
+	// the last value must be == 0
+	return c[len(c)-1].Sign() == 0
+}
+
+// halfColumnBlocks converts a column that passed checkHalfColumn into the two Plaintext blocks it may encode.
+// A plaintext m gives the column m - 1/2, but LLL may just as well return its negation,
+// so the bits are either the 1/2 entries or the -1/2 entries, the first index being the most significant.
+func halfColumnBlocks(col matrix.Vector) [2]*big.Int {
+	n := len(col) - 1
+
+	pos, neg := new(big.Int), new(big.Int)
+	for j := 0; j < n; j++ {
+		if col[j].Sign() > 0 {
+			pos.SetBit(pos, n-1-j, 1)
+		} else {
+			neg.SetBit(neg, n-1-j, 1)
+		}
+	}
+
+	return [2]*big.Int{pos, neg}
+}
+
+// columnBlock converts a column that passed checkColumn into a Plaintext block.
+// col[j] is the bit matching public[j], the first index being the most significant.
+func columnBlock(col matrix.Vector) *big.Int {
+	n := len(col) - 1
+
+	block := new(big.Int)
+	for j := 0; j < n; j++ {
+		block.SetBit(block, n-1-j, col[j].Num().Bit(0))
 	}
+
+	return block
+}
+
+// AttackOptions configures Attack. The zero value reduces the LagariasOdlyzkoLattice with LLL,
+// delta = 3/4 until the basis is reduced, one block at a time, silently.
+type AttackOptions struct {
+	// Lattice is the lattice reduced for every block.
+	Lattice Lattice
+
+	// Scale is the factor N multiplying the public key row, so short vectors leave it at 0.
+	// If nil, 1 for the LagariasOdlyzkoLattice and ceil(sqrt(n)) for the CJLOSSLattice of n public values.
+	Scale *big.Int
+
+	// Delta is LLL's Lovász constant, between (1/4, 1], 3/4 if nil.
+	Delta *big.Rat
+
+	// MaxIterations bounds LLL's steps, unlimited if 0. A block whose reduction stops early reports it in its ReductionStats.
+	MaxIterations int
+
+	// Workers is the number of blocks attacked at once, 1 if 0.
+	Workers int
+
+	// Logger receives the initial and reduced lattice bases, nothing is logged if nil.
+	Logger *log.Logger
+}
+
+// Candidate is a column of the reduced basis in the form of a plaintext block.
+type Candidate struct {
+	// Column is the index of the column in the reduced basis, -1 for a 0 Ciphertext block, which needs no lattice.
+	Column int
+
+	// Block is the plaintext block the column encodes.
+	// A CJLOSSLattice column encodes a block or its complement, so it gives two Candidates.
+	Block *big.Int
+
+	// Verified reports whether Block encrypts to the attacked Ciphertext block under the PublicKey.
+	// The PublicKey of a valid Knapsack encrypts every block differently, so a Verified Block is the plaintext.
+	Verified bool
+}
+
+// BlockResult is the outcome of the lattice attack against one Ciphertext block.
+type BlockResult struct {
+	// Index is the index of the attacked Ciphertext block.
+	Index int
+
+	Candidates []Candidate
+	Reduction  ReductionStats
+
+	// Block is the recovered plaintext block, the first Verified Candidate, or nil.
+	Block *big.Int
+}
+
+// Recovered reports whether one of the Candidates was Verified.
+func (b BlockResult) Recovered() bool {
+	return b.Block != nil
+}
+
+// AttackResult is the outcome of Attack.
+type AttackResult struct {
+	// Bits is the number of bits in each Candidate's Block.
+	Bits int
+
+	// Blocks holds one BlockResult per Ciphertext block, in order.
+	Blocks   []BlockResult
+	Duration time.Duration
+}
+
+// Recovered reports whether every block was recovered.
+func (r *AttackResult) Recovered() bool {
+	for _, b := range r.Blocks {
+		if !b.Recovered() {
+			return false
+		}
+	}
+
+	return len(r.Blocks) > 0
+}
+
+// Plaintext reassembles the recovered blocks, or returns nil if a block wasn't recovered.
+func (r *AttackResult) Plaintext() Plaintext {
+	if !r.Recovered() {
+		return nil
+	}
+
+	plain := make(Plaintext, len(r.Blocks))
+	for i, b := range r.Blocks {
+		plain[i] = b.Block
+	}
+
+	return plain
+}
+
+// Data reassembles the recovered blocks into the original data, removing its padding.
+func (r *AttackResult) Data() ([]byte, error) {
+	plain := r.Plaintext()
+	if plain == nil {
+		return nil, fmt.Errorf("not every block was recovered")
+	}
+
+	return FromPlaintextBits(r.Bits, plain)
+}
+
+// Attack runs Shamir's lattice attack against every block of cipher, reducing the AttackOptions' Lattice with LLL
+// and reporting every column of the reduced basis that looks like a plaintext block.
+// Only the public key and ciphertext are needed: Candidates are verified by encrypting them again.
+func Attack(cipher Ciphertext, public PublicKey, opts AttackOptions) (*AttackResult, error) {
+	if len(public) == 0 {
+		return nil, fmt.Errorf("public key is empty")
+	}
+	if len(cipher) == 0 {
+		return nil, fmt.Errorf("ciphertext is empty")
+	}
+
+	delta := opts.Delta
+	if delta == nil {
+		delta = big.NewRat(3, 4)
+	}
+	if delta.Cmp(big.NewRat(1, 4)) != 1 || delta.Cmp(big.NewRat(1, 1)) == 1 {
+		return nil, fmt.Errorf("delta = %v must be between (1/4, 1]", delta)
+	}
+
+	maxIterations := opts.MaxIterations
+	if maxIterations < 0 {
+		return nil, fmt.Errorf("MaxIterations must be >= 0")
+	}
+
+	if opts.Lattice != LagariasOdlyzkoLattice && opts.Lattice != CJLOSSLattice {
+		return nil, fmt.Errorf("unknown lattice %v", opts.Lattice)
+	}
+
+	scale := opts.Scale
+	if scale == nil {
+		scale = opts.Lattice.defaultScale(len(public))
+	}
+	if scale.Sign() != 1 {
+		return nil, fmt.Errorf("Scale = %v must be > 0", scale)
+	}
+
+	workers := opts.Workers
+	if workers == 0 {
+		workers = 1
+	}
+	if workers < 0 {
+		return nil, fmt.Errorf("Workers must be >= 0")
+	}
+
+	start := time.Now()
+
+	a := &attacker{
+		public:        public,
+		lattice:       opts.Lattice,
+		scale:         scale,
+		basis:         publicBasis(public, opts.Lattice, scale),
+		delta:         delta,
+		maxIterations: maxIterations,
+		logger:        opts.Logger,
+	}
+
+	result := &AttackResult{Bits: public.Bits(), Blocks: make([]BlockResult, len(cipher))}
+
+	// each goroutine only writes its own block's result
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, c := range cipher {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			result.Blocks[i] = a.attackBlock(i, c)
+		}()
+	}
+	wg.Wait()
+
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// attacker holds what Attack shares between blocks.
+type attacker struct {
+	public        PublicKey
+	lattice       Lattice
+	scale         *big.Int
+	basis         matrix.Matrix // publicBasis, copied for every block
+	delta         *big.Rat
+	maxIterations int
+	logger        *log.Logger
+}
+
+func (a *attacker) logf(format string, v ...any) {
+	if a.logger != nil {
+		a.logger.Printf(format, v...)
+	}
+}
+
+// verify reports whether the plaintext block b encrypts to the ciphertext block c.
+func (a *attacker) verify(b, c *big.Int) bool {
+	return a.public.Encrypt(Plaintext{b})[0].Cmp(c) == 0
+}
+
+// attackBlock runs the lattice attack against the i-th Ciphertext block c.
+func (a *attacker) attackBlock(i int, c *big.Int) BlockResult {
+	result := BlockResult{Index: i}
+	add := func(candidate Candidate) {
+		result.Candidates = append(result.Candidates, candidate)
+		if candidate.Verified && result.Block == nil {
+			result.Block = candidate.Block
+		}
+	}
+
+	// a 0 block would make the basis singular, but it can only be the encryption of 0
+	if c.Sign() == 0 {
+		b := big.NewInt(0)
+		add(Candidate{Column: -1, Block: b, Verified: true})
+		return result
+	}
+
+	m := blockBasis(a.basis, c, a.lattice, a.scale)
+	a.logf("block %d initial matrix:\n%s\n", i, m)
+
+	reduced, stats := lll(m, a.delta, a.maxIterations)
+	result.Reduction = stats
+	a.logf("block %d reduced matrix after %d iterations:\n%s\n", i, stats.Iterations, reduced)
+
+	for j := 0; j < reduced.Width(); j++ {
+		col := reduced.Col(j)
+
+		var blocks []*big.Int
+		switch {
+		case a.lattice == CJLOSSLattice && checkHalfColumn(col):
+			both := halfColumnBlocks(col)
+			blocks = both[:]
+		case a.lattice == LagariasOdlyzkoLattice && checkColumn(col):
+			blocks = []*big.Int{columnBlock(col)}
+		default:
+			continue
+		}
+
+		for _, b := range blocks {
+			add(Candidate{Column: j, Block: b, Verified: a.verify(b, c)})
+		}
+		a.logf("block %d suspected plaintext found at column %d: %v\n", i, j, col)
+	}
+
+	return result
 }
@@ -1,42 +1,70 @@
 package knapsack
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/chronotrax/knapsack/matrix"
+	"log"
 	"math/big"
 	"reflect"
+	"strings"
 	"testing"
 )
 
-func Test_gs(t *testing.T) {
-	type args struct {
-		b matrix.Matrix
-	}
-	tests := []struct {
-		name string
-		args args
-		want matrix.Matrix
-	}{
-		{
-			name: "1",
-			args: args{
-				b: matrix.NewMatrixFull(3, 3,
-					matrix.Vector{new(big.Rat).SetInt64(1), new(big.Rat).SetInt64(1), new(big.Rat).SetInt64(1),
-						new(big.Rat).SetInt64(-1), new(big.Rat).SetInt64(0), new(big.Rat).SetInt64(1),
-						new(big.Rat).SetInt64(1), new(big.Rat).SetInt64(1), new(big.Rat).SetInt64(2)}),
-			},
-			want: matrix.NewMatrixFull(3, 3,
-				matrix.Vector{new(big.Rat).SetInt64(1), new(big.Rat).SetFrac64(1, 3), new(big.Rat).SetFrac64(-1, 2),
-					new(big.Rat).SetInt64(-1), new(big.Rat).SetFrac64(2, 3), new(big.Rat).SetInt64(0),
-					new(big.Rat).SetInt64(1), new(big.Rat).SetFrac64(1, 3), new(big.Rat).SetFrac64(1, 2)}),
-		},
+// gs is the textbook Gram–Schmidt algorithm, the reference gramSchmidt and IsLLLReduced are checked against.
+func gs(b matrix.Matrix) (x, y matrix.Matrix) {
+	n := b.Height()
+	x = matrix.NewMatrixEmpty(n, n)
+	y = matrix.NewMatrixEmpty(n, n)
+
+	// x0 = b0 (b0 is M's first column, not row)
+	x.SetCol(0, b.Col(0))
+
+	// for j = 1 to n
+	for j := 1; j < n; j++ {
+		// xj = bj
+		x.SetCol(j, b.Col(j))
+
+		// for i = 0 to j - 1 (inclusive)
+		for i := 0; i <= j-1; i++ {
+			// xi * bj
+			prod := new(big.Rat).Set(matrix.DotProduct(x.Col(i), b.Col(j)))
+
+			// yij = (xi * bj) / ||xi||^2
+			co := new(big.Rat).Quo(prod, matrix.DotProduct(x.Col(i), x.Col(i)))
+			// we must keep track of coefficients for LLL
+			y[j][i] = co
+
+			// xj = xj - yij * xi
+			x.SetCol(j, matrix.SubtractVec(x.Col(j), matrix.MulRatOnVec(co, x.Col(i))))
+		}
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got, _ := gs(tt.args.b); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("gs() = %v, want %v", got, tt.want)
+
+	return x, y
+}
+
+// gsReduced is IsLLLReduced computed from gs: every |mu| <= 1/2 and every Lovász condition holds.
+// b must be linearly independent.
+func gsReduced(b matrix.Matrix, delta *big.Rat) bool {
+	x, y := gs(b)
+	half := big.NewRat(1, 2)
+
+	for k := 1; k < b.Width(); k++ {
+		for j := 0; j < k; j++ {
+			if new(big.Rat).Abs(y[k][j]).Cmp(half) == 1 {
+				return false
 			}
-		})
+		}
+
+		// ||x_k||^2 >= (delta - mu_k,k-1^2) ||x_k-1||^2
+		mu2 := new(big.Rat).Mul(y[k][k-1], y[k][k-1])
+		bound := new(big.Rat).Mul(new(big.Rat).Sub(delta, mu2), matrix.DotProduct(x.Col(k-1), x.Col(k-1)))
+		if matrix.DotProduct(x.Col(k), x.Col(k)).Cmp(bound) == -1 {
+			return false
+		}
 	}
+
+	return true
 }
 
 func Test_lll(t *testing.T) {
@@ -66,9 +94,428 @@ func Test_lll(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := lll(tt.args.b, tt.args.delta, tt.args.maxIterations); !reflect.DeepEqual(got, tt.want) {
+			got, stats := lll(tt.args.b, tt.args.delta, tt.args.maxIterations)
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("lll() = %v, want %v", got, tt.want)
 			}
+			if !stats.Reduced || !IsLLLReduced(got, tt.args.delta) {
+				t.Errorf("lll() = %v is not LLL reduced, stats %+v", got, stats)
+			}
 		})
 	}
 }
+
+// ints builds a square Matrix from rows of integers.
+func ints(rows ...[]int64) matrix.Matrix {
+	m := matrix.NewMatrixEmpty(len(rows), len(rows))
+	for i, row := range rows {
+		for j, x := range row {
+			m[i][j] = big.NewRat(x, 1)
+		}
+	}
+
+	return m
+}
+
+func TestIsLLLReduced(t *testing.T) {
+	delta := big.NewRat(3, 4)
+	tests := []struct {
+		name      string
+		basis     matrix.Matrix
+		want      bool
+		dependent bool
+	}{
+		{name: "identity", basis: ints([]int64{1, 0}, []int64{0, 1}), want: true},
+		{name: "reduced", basis: ints([]int64{1, 40}, []int64{30, 5}), want: true},
+		{name: "not size reduced", basis: ints([]int64{47, 95}, []int64{215, 460})},
+		{name: "lovász", basis: ints([]int64{2, 0}, []int64{0, 1})},
+		{name: "dependent", basis: ints([]int64{1, 2}, []int64{1, 2}), dependent: true},
+		{name: "3d", basis: ints([]int64{1, -1, 3}, []int64{1, 0, 5}, []int64{1, 2, 6})},
+	}
+	for _, tt := range tests {
+		if got := IsLLLReduced(tt.basis, delta); got != tt.want {
+			t.Errorf("%s: IsLLLReduced(%v) = %v, want %v", tt.name, tt.basis, got, tt.want)
+		}
+		// gs can't orthogonalize a dependent basis
+		if !tt.dependent && gsReduced(tt.basis, delta) != tt.want {
+			t.Errorf("%s: gsReduced(%v) = %v, want %v", tt.name, tt.basis, !tt.want, tt.want)
+		}
+	}
+}
+
+func TestGramSchmidt(t *testing.T) {
+	b := ints([]int64{3, -1, 4, 1}, []int64{5, 9, -2, 6}, []int64{5, 3, 5, -8}, []int64{9, 7, 9, 3})
+	x, y := gs(b)
+
+	// the incremental coefficients match gs
+	g := newGramSchmidt(b.Width())
+	for k := 0; k < b.Width(); k++ {
+		if !g.orthogonalize(b, k) {
+			t.Fatalf("column %d is dependent", k)
+		}
+		if want := matrix.DotProduct(x.Col(k), x.Col(k)); g.B[k].Cmp(want) != 0 {
+			t.Errorf("B[%d] = %v, want %v", k, g.B[k], want)
+		}
+		for j := 0; j < k; j++ {
+			if g.mu[k][j].Cmp(y[k][j]) != 0 {
+				t.Errorf("mu[%d][%d] = %v, want %v", k, j, g.mu[k][j], y[k][j])
+			}
+		}
+	}
+
+	// and stay up to date through lll, which gs agrees reduced the basis
+	delta := big.NewRat(99, 100)
+	if IsLLLReduced(b, delta) || gsReduced(b, delta) {
+		t.Errorf("%v is already LLL reduced", b)
+	}
+	reduced, stats := lll(b.Copy(), delta, 0)
+	if !stats.Reduced || stats.Swaps == 0 || !IsLLLReduced(reduced, delta) || !gsReduced(reduced, delta) {
+		t.Errorf("lll() = %v is not LLL reduced, stats %+v", reduced, stats)
+	}
+}
+
+func TestLLLAttackLattice(t *testing.T) {
+	k, err := NewKnapsackBits(16, Options{Rand: NewSeededRand([]byte("lll"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := k.Encrypt(NewPlaintextBits(16, []byte("Hi")))[0]
+	scale := CJLOSSLattice.defaultScale(16)
+
+	for _, lattice := range []Lattice{LagariasOdlyzkoLattice, CJLOSSLattice} {
+		basis := blockBasis(publicBasis(k.Public, lattice, scale), c, lattice, scale)
+		delta := big.NewRat(99, 100)
+
+		reduced, stats := lll(basis.Copy(), delta, 0)
+		if !stats.Reduced || stats.Dimension != 17 || !IsLLLReduced(reduced, delta) {
+			t.Errorf("%v: lll() is not LLL reduced, stats %+v", lattice, stats)
+		}
+
+		// running out of iterations leaves a basis that isn't reported as reduced
+		if _, stats := lll(basis.Copy(), delta, 1); stats.Reduced || stats.Iterations != 1 {
+			t.Errorf("%v: 1 iteration gave stats %+v", lattice, stats)
+		}
+	}
+}
+
+func TestAttack(t *testing.T) {
+	k, err := NewKnapsackBits(8, Options{Rand: NewSeededRand([]byte("attack"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("result", func(t *testing.T) {
+		data := []byte("Hi")
+		plain := k.NewPlaintext(data)
+		result, err := Attack(k.Encrypt(plain), k.Public, AttackOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.Bits != 8 || len(result.Blocks) != len(plain) {
+			t.Fatalf("got %d bits and %d blocks, want 8 bits and %d blocks", result.Bits, len(result.Blocks), len(plain))
+		}
+
+		for i, b := range result.Blocks {
+			if b.Index != i || b.Reduction.Dimension != 9 || b.Reduction.Iterations < 1 || !b.Reduction.Reduced {
+				t.Errorf("block %d: index %d, reduction %+v", i, b.Index, b.Reduction)
+			}
+
+			// every block encrypts differently, so only the plaintext itself is verified
+			for _, c := range b.Candidates {
+				if c.Verified != (c.Block.Cmp(plain[i]) == 0) {
+					t.Errorf("block %d column %d: block %b, verified = %v", i, c.Column, c.Block, c.Verified)
+				}
+			}
+			if b.Recovered() && b.Block.Cmp(plain[i]) != 0 {
+				t.Errorf("block %d: recovered %b, want %b", i, b.Block, plain[i])
+			}
+		}
+
+		if got, err := result.Data(); err != nil || string(got) != string(data) {
+			t.Errorf("Data() = %q, %v, want %q", got, err, data)
+		}
+	})
+
+	t.Run("parallel", func(t *testing.T) {
+		data := []byte("Hello World!")
+		cipher := k.Encrypt(k.NewPlaintext(data))
+
+		sequential, err := Attack(cipher, k.Public, AttackOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		parallel, err := Attack(cipher, k.Public, AttackOptions{Workers: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range sequential.Blocks {
+			s, p := sequential.Blocks[i], parallel.Blocks[i]
+			if p.Index != i || !reflect.DeepEqual(s.Candidates, p.Candidates) || s.Reduction != p.Reduction {
+				t.Errorf("block %d: sequential %+v, parallel %+v", i, s, p)
+			}
+		}
+	})
+
+	t.Run("cjloss", func(t *testing.T) {
+		plain := k.NewPlaintext([]byte("Hi"))
+		result, err := Attack(k.Encrypt(plain), k.Public, AttackOptions{Lattice: CJLOSSLattice, Scale: big.NewInt(4)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, b := range result.Blocks {
+			// every ±1/2 column gives a block and its complement
+			if len(b.Candidates)%2 != 0 {
+				t.Errorf("block %d: %d candidates, want pairs", i, len(b.Candidates))
+			}
+			for _, c := range b.Candidates {
+				if c.Verified != (c.Block.Cmp(plain[i]) == 0) {
+					t.Errorf("block %d column %d: block %b, verified = %v", i, c.Column, c.Block, c.Verified)
+				}
+			}
+		}
+
+		if !result.Recovered() {
+			t.Errorf("plaintext not recovered")
+		}
+	})
+
+	t.Run("zero block", func(t *testing.T) {
+		data := []byte{0, 1}
+		result, err := Attack(k.Encrypt(k.NewPlaintext(data)), k.Public, AttackOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if b := result.Blocks[0]; !b.Recovered() || b.Block.Sign() != 0 {
+			t.Errorf("a 0 block was not recovered: %+v", b)
+		}
+	})
+
+	t.Run("reassemble", func(t *testing.T) {
+		data := []byte("Hi")
+		plain := NewPlaintextBits(5, data)
+
+		result := &AttackResult{Bits: 5}
+		for i, b := range plain {
+			result.Blocks = append(result.Blocks, BlockResult{Index: i, Block: b})
+		}
+
+		got, err := result.Data()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(data) {
+			t.Errorf("got %q, want %q", got, data)
+		}
+
+		result.Blocks[1].Block = nil
+		if result.Recovered() || result.Plaintext() != nil {
+			t.Errorf("a missing block still gave a Plaintext")
+		}
+		if _, err := result.Data(); err == nil {
+			t.Errorf("err = nil")
+		}
+	})
+
+	t.Run("max iterations", func(t *testing.T) {
+		result, err := Attack(k.Encrypt(k.NewPlaintext([]byte("Hi"))), k.Public, AttackOptions{MaxIterations: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, b := range result.Blocks {
+			if b.Reduction.Reduced || b.Reduction.Iterations != 2 {
+				t.Errorf("block %d: reduction %+v, want 2 iterations and not reduced", i, b.Reduction)
+			}
+		}
+	})
+
+	t.Run("logger", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		data := []byte("Hi")
+		if _, err := Attack(k.Encrypt(k.NewPlaintext(data)), k.Public, AttackOptions{Logger: log.New(buf, "", 0)}); err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(buf.String(), "initial matrix") || !strings.Contains(buf.String(), "reduced matrix") {
+			t.Errorf("log = %q, want both matrices", buf.String())
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		cipher := k.Encrypt(k.NewPlaintext([]byte("Hi")))
+
+		tests := []struct {
+			name   string
+			cipher Ciphertext
+			public PublicKey
+			opts   AttackOptions
+		}{
+			{name: "empty public key", cipher: cipher},
+			{name: "empty ciphertext", public: k.Public},
+			{name: "delta too small", cipher: cipher, public: k.Public, opts: AttackOptions{Delta: big.NewRat(1, 4)}},
+			{name: "delta too large", cipher: cipher, public: k.Public, opts: AttackOptions{Delta: big.NewRat(5, 4)}},
+			{name: "negative iterations", cipher: cipher, public: k.Public, opts: AttackOptions{MaxIterations: -1}},
+			{name: "negative workers", cipher: cipher, public: k.Public, opts: AttackOptions{Workers: -1}},
+			{name: "unknown lattice", cipher: cipher, public: k.Public, opts: AttackOptions{Lattice: CJLOSSLattice + 1}},
+			{name: "zero scale", cipher: cipher, public: k.Public, opts: AttackOptions{Scale: big.NewInt(0)}},
+			{name: "negative scale", cipher: cipher, public: k.Public, opts: AttackOptions{Lattice: CJLOSSLattice, Scale: big.NewInt(-2)}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := Attack(tt.cipher, tt.public, tt.opts); err == nil {
+					t.Errorf("err = nil")
+				}
+			})
+		}
+	})
+}
+
+// combine returns the lattice vector sum(bits[i] * col i) + last * the last column of m.
+func combine(m matrix.Matrix, bits []int64, last int64) matrix.Vector {
+	n := m.Width() - 1
+	v := matrix.MulRatOnVec(big.NewRat(last, 1), m.Col(n))
+	for i, bit := range bits {
+		v = matrix.SubtractVec(v, matrix.MulRatOnVec(big.NewRat(-bit, 1), m.Col(i)))
+	}
+
+	return v
+}
+
+// Above LagariasOdlyzkoDensity, the ±1/2 vector of the CJLOSSLattice is still the shortest
+// while the 0/1 vector of the LagariasOdlyzkoLattice usually isn't, so only the CJLOSSLattice breaks these keys.
+func TestAttackDensity(t *testing.T) {
+	data := []byte("Hello")
+	const keys = 8
+
+	recovered := map[Lattice]int{}
+	for s := range keys {
+		k, err := NewKnapsackBits(20, Options{Rand: NewSeededRand([]byte(fmt.Sprint("cjloss", s))), Set: DensityGenerator{Density: 0.9}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a := Analyze(k.Public); a.LagariasOdlyzko || !a.CJLOSS {
+			t.Fatalf("key %d: density %v, want between %v and %v", s, a.Density, LagariasOdlyzkoDensity, CJLOSSDensity)
+		}
+
+		cipher := k.Encrypt(k.NewPlaintext(data))
+		for _, lattice := range []Lattice{LagariasOdlyzkoLattice, CJLOSSLattice} {
+			result, err := Attack(cipher, k.Public, AttackOptions{Lattice: lattice})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := result.Data(); err == nil {
+				if string(got) != string(data) {
+					t.Errorf("key %d %v: got %q, want %q", s, lattice, got, data)
+				}
+				recovered[lattice]++
+			}
+		}
+	}
+
+	if recovered[CJLOSSLattice] != keys {
+		t.Errorf("CJLOSS lattice recovered %d of %d keys, want all", recovered[CJLOSSLattice], keys)
+	}
+	if recovered[LagariasOdlyzkoLattice] > keys/2 {
+		t.Errorf("Lagarias–Odlyzko lattice recovered %d of %d keys, want at most half", recovered[LagariasOdlyzkoLattice], keys)
+	}
+}
+
+func TestLattice(t *testing.T) {
+	k, err := NewKnapsackBits(8, Options{Rand: NewSeededRand([]byte("lattice"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := big.NewInt(0b10110010)
+	bits := []int64{1, 0, 1, 1, 0, 0, 1, 0}
+	c := k.Public.Encrypt(Plaintext{block})[0]
+	scale := big.NewInt(3)
+
+	t.Run("lagarias-odlyzko", func(t *testing.T) {
+		m := blockBasis(publicBasis(k.Public, LagariasOdlyzkoLattice, scale), c, LagariasOdlyzkoLattice, scale)
+		if want := new(big.Rat).SetInt(new(big.Int).Mul(scale, k.Public[2])); m[8][2].Cmp(want) != 0 {
+			t.Errorf("public row = %v, want %v", m[8][2], want)
+		}
+
+		// the plaintext bits, with the scaled sum cancelling the -c corner
+		v := combine(m, bits, 1)
+		if !checkColumn(v) || columnBlock(v).Cmp(block) != 0 {
+			t.Errorf("plaintext vector = %v, want the bits of %b over 0", v, block)
+		}
+		if got := matrix.DotProduct(v, v); got.Cmp(big.NewRat(4, 1)) != 0 {
+			t.Errorf("||v||^2 = %v, want the 4 set bits", got)
+		}
+	})
+
+	t.Run("cjloss", func(t *testing.T) {
+		m := blockBasis(publicBasis(k.Public, CJLOSSLattice, scale), c, CJLOSSLattice, scale)
+		if m[3][8].Cmp(big.NewRat(1, 2)) != 0 || m[8][8].Cmp(new(big.Rat).SetInt(new(big.Int).Mul(scale, c))) != 0 {
+			t.Errorf("last column = %v, want 1/2 over %v*c", m.Col(8), scale)
+		}
+
+		// the plaintext bits shifted to ±1/2, whatever their weight ||v||^2 = n/4
+		v := combine(m, bits, -1)
+		if !checkHalfColumn(v) {
+			t.Fatalf("plaintext vector = %v, want ±1/2 over 0", v)
+		}
+		if got := matrix.DotProduct(v, v); got.Cmp(big.NewRat(2, 1)) != 0 {
+			t.Errorf("||v||^2 = %v, want 2", got)
+		}
+
+		complement := big.NewInt(0b01001101)
+		if got := halfColumnBlocks(v); got[0].Cmp(block) != 0 || got[1].Cmp(complement) != 0 {
+			t.Errorf("halfColumnBlocks(v) = %b, want [%b %b]", got, block, complement)
+		}
+		if got := halfColumnBlocks(matrix.MulRatOnVec(big.NewRat(-1, 1), v)); got[0].Cmp(complement) != 0 || got[1].Cmp(block) != 0 {
+			t.Errorf("halfColumnBlocks(-v) = %b, want [%b %b]", got, complement, block)
+		}
+	})
+
+	t.Run("check half column", func(t *testing.T) {
+		half, one := big.NewRat(1, 2), big.NewRat(1, 1)
+		tests := []struct {
+			name string
+			col  matrix.Vector
+			want bool
+		}{
+			{name: "halves", col: matrix.Vector{half, new(big.Rat).Neg(half), big.NewRat(0, 1)}, want: true},
+			{name: "one", col: matrix.Vector{half, one, big.NewRat(0, 1)}},
+			{name: "zero entry", col: matrix.Vector{half, big.NewRat(0, 1), big.NewRat(0, 1)}},
+			{name: "last not zero", col: matrix.Vector{half, half, one}},
+		}
+		for _, tt := range tests {
+			if got := checkHalfColumn(tt.col); got != tt.want {
+				t.Errorf("%s: checkHalfColumn(%v) = %v, want %v", tt.name, tt.col, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("default scale", func(t *testing.T) {
+		for _, tt := range []struct {
+			lattice Lattice
+			n       int
+			want    int64
+		}{
+			{LagariasOdlyzkoLattice, 8, 1},
+			{CJLOSSLattice, 8, 3},
+			{CJLOSSLattice, 9, 3},
+			{CJLOSSLattice, 10, 4},
+		} {
+			if got := tt.lattice.defaultScale(tt.n); got.Cmp(big.NewInt(tt.want)) != 0 {
+				t.Errorf("%v.defaultScale(%d) = %v, want %d", tt.lattice, tt.n, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("string", func(t *testing.T) {
+		if got := CJLOSSLattice.String(); got != "cjloss" {
+			t.Errorf("String() = %q", got)
+		}
+	})
+}
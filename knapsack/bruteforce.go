@@ -3,143 +3,188 @@ package knapsack
 import (
 	"context"
 	"fmt"
+	"log"
 	"math"
 	"math/big"
-	"runtime"
 	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// BruteForce finds private keys given a Ciphertext & PublicKey.
-// expected is the original data to compare against.
-// maxKeys is the max # of keys to brute force before stopping
-func BruteForce(blockSize int, cipher Ciphertext, public PublicKey, expected []byte, maxKeys uint64) {
-	maxVal := big.NewInt(math.MaxInt)
-	//maxVal := big.NewInt(4)
-	u := big.NewInt(1)
+// bruteForceProgress is how often BruteForce logs its progress.
+const bruteForceProgress = 10 * time.Second
 
-	validKeys := make(chan *PrivateKey)
-	keysFound := uint64(0)
+// BruteForceOptions configures BruteForce.
+type BruteForceOptions struct {
+	// MaxKeys is the # of keys to find before stopping, 1 if 0.
+	MaxKeys uint64
 
-	threads := int64(runtime.NumCPU())
-	//threads := int64(1)
-	workers := make(chan *big.Int, threads)
-	fmt.Printf("using %d thread(s)\n", threads)
+	// Workers is the number of moduli u tried at once, 1 if 0.
+	Workers int
+
+	// Logger receives the progress and every key found, nothing is logged if nil.
+	Logger *log.Logger
+}
+
+// FoundKey is a private key found by BruteForce.
+type FoundKey struct {
+	Private *PrivateKey
+
+	// Data is the plaintext Private decrypts the Ciphertext to.
+	Data []byte
+
+	// Elapsed is the time from the start of BruteForce until Private was found.
+	Elapsed time.Duration
+}
+
+// BruteForceResult is the outcome of BruteForce.
+type BruteForceResult struct {
+	// Keys holds every key found, in the order they were found.
+	Keys     []FoundKey
+	Duration time.Duration
+}
+
+// BruteForce finds private keys given a Ciphertext & PublicKey, trying every u and every v < u in turn.
+// A key is valid if the plaintext it decrypts to is correctly padded and encrypts back to cipher under public,
+// so the original data isn't needed.
+// It stops after opts.MaxKeys keys, which may take until u reaches math.MaxInt64.
+func BruteForce(cipher Ciphertext, public PublicKey, opts BruteForceOptions) (*BruteForceResult, error) {
+	if len(public) == 0 {
+		return nil, fmt.Errorf("public key is empty")
+	}
+	if len(cipher) == 0 {
+		return nil, fmt.Errorf("ciphertext is empty")
+	}
+
+	maxKeys := opts.MaxKeys
+	if maxKeys == 0 {
+		maxKeys = 1
+	}
+	workers := max(opts.Workers, 1)
+
+	logf := func(format string, v ...any) {
+		if opts.Logger != nil {
+			opts.Logger.Printf(format, v...)
+		}
+	}
+	logf("using %d worker(s)", workers)
 
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	t := time.Now()
+	start := time.Now()
 
-	// ticker goroutine
+	// current is the last u handed to a worker, read by the progress goroutine
+	var current atomic.Int64
+
+	// hand out every u until enough keys are found
+	us := make(chan int64)
 	go func() {
-		ticker := time.NewTicker(10 * time.Second)
-		tracker := big.NewInt(0)
-		for {
+		defer close(us)
+		for u := int64(1); u < math.MaxInt64; u++ {
 			select {
 			case <-ctx.Done():
 				return
-			case <-ticker.C:
-				t2 := time.Now().Sub(t).Round(time.Second)
-				change := new(big.Int).Sub(u, tracker)
-				speed := float64(change.Int64() / 10)
-				fmt.Printf("time elapsed: %v, speed: (u per second) %.02f/s, currently on: u=%d\n", t2, speed, u)
-				tracker = new(big.Int).Set(u)
+			case us <- u:
+				current.Store(u)
 			}
 		}
+		logf("max value reached")
 	}()
 
-	// validKeys goroutine
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case p := <-validKeys:
-				fmt.Printf("time taken: %v, found private key! v=%d u=%d\n", time.Now().Sub(t), p.V, p.U)
-
-				keysFound++
-				if keysFound >= maxKeys {
-					fmt.Println("max valid validKeys found")
-					cancel()
-				}
+	found := make(chan *PrivateKey)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range us {
+				worker(ctx, big.NewInt(u), public, cipher, found)
 			}
-		}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(found)
 	}()
 
-	// worker goroutine
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case tryU := <-workers: // acquire a thread and a `u` value to try
-				//fmt.Printf("sending v=%d, u=%d\n", u)
-				k := &Knapsack{
-					BlockSize: blockSize,
-					Private: &PrivateKey{
-						U: tryU,
-					},
-					Public: public,
+	if opts.Logger != nil {
+		go func() {
+			ticker := time.NewTicker(bruteForceProgress)
+			defer ticker.Stop()
+
+			tracker := int64(0)
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					u := current.Load()
+					speed := float64(u-tracker) / bruteForceProgress.Seconds()
+					logf("time elapsed: %v, speed: (u per second) %.02f/s, currently on: u=%d",
+						time.Since(start).Round(time.Second), speed, u)
+					tracker = u
 				}
+			}
+		}()
+	}
 
-				go func() {
-					worker(ctx, k, cipher, expected, validKeys) // blocks until worker completes
+	// found is closed once every worker has stopped, so none is left blocked
+	result := new(BruteForceResult)
+	for p := range found {
+		if uint64(len(result.Keys)) >= maxKeys {
+			continue
+		}
 
-					<-workers // release a thread
-					//fmt.Println("worker goroutine completed")
-				}()
-			}
+		key := FoundKey{Private: p, Elapsed: time.Since(start)}
+		if plain, err := p.Decrypt(cipher); err == nil {
+			key.Data, _ = FromPlaintextBits(p.Bits(), plain)
 		}
-	}()
+		result.Keys = append(result.Keys, key)
+		logf("time taken: %v, found private key! v=%d u=%d", key.Elapsed, p.V, p.U)
 
-	working := true
-	for working {
-		select {
-		case <-ctx.Done():
-			working = false
-		default:
-			// send a `u` value to worker channel
-			workers <- new(big.Int).Set(u)
-
-			// if u == maxVal
-			if u.Cmp(maxVal) == 0 {
-				fmt.Println("max value reached")
-				cancel()
-				working = false
-			} else {
-				u.Add(u, big.NewInt(1))
-			}
+		if uint64(len(result.Keys)) >= maxKeys {
+			logf("max valid keys found")
+			cancel()
 		}
 	}
-	fmt.Println("# of valid validKeys found: ", keysFound)
+
+	result.Duration = time.Since(start)
+	return result, nil
 }
 
-func worker(ctx context.Context, k *Knapsack, cipher Ciphertext, expected []byte, keys chan<- *PrivateKey) {
+// worker tries every v < u, sending the valid keys to keys until ctx is done.
+func worker(ctx context.Context, u *big.Int, public PublicKey, cipher Ciphertext, keys chan<- *PrivateKey) {
 	// for v < u
-	for v := big.NewInt(1); v.Cmp(k.Private.U) == -1; v.Add(v, big.NewInt(1)) {
-		select {
-		case <-ctx.Done():
+	for v := big.NewInt(1); v.Cmp(u) == -1; v.Add(v, big.NewInt(1)) {
+		if ctx.Err() != nil {
 			return
-		default:
-			k.Private.V = v
+		}
 
-			//time.Sleep(time.Duration(mathRand.Int()%10+1) * time.Second)
-			//k.Private.V = big.NewInt(70000)
-			//k.Private.U = big.NewInt(70001)
+		inverse, err := modInverse(v, u)
+		if err != nil {
+			continue
+		}
 
-			plain, err := k.Decrypt(cipher)
-			if err != nil {
-				//fmt.Printf("FAIL: error: v=%d u=%d\n", k.Private.V, k.Private.U)
-				continue
-			}
+		// the Set is unknown, so recreate it from the PublicKey,
+		// sorting it in case the PublicKey was permuted
+		s, perm := sortedPerm(recoverSet(public, u, inverse))
+		plain := unpermuteBits(decrypt(s, u, inverse, cipher), perm, len(s))
 
-			data := k.FromPlaintext(plain)
+		if _, err := FromPlaintextBits(len(public), plain); err != nil {
+			continue
+		}
+
+		// the plaintext must encrypt back to the ciphertext
+		if !slices.EqualFunc(public.Encrypt(plain), cipher, func(a, b *big.Int) bool { return a.Cmp(b) == 0 }) {
+			continue
+		}
 
-			if slices.Equal(data, expected) {
-				keys <- k.Private
-			} // else {
-			//fmt.Printf("FAIL: not equal: v=%d u=%d\n", k.Private.V, k.Private.U)
-			//}
+		select {
+		case <-ctx.Done():
+			return
+		case keys <- &PrivateKey{Set: s, V: new(big.Int).Set(v), U: u, Perm: perm}:
 		}
 	}
 }
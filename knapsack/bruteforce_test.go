@@ -0,0 +1,50 @@
+package knapsack
+
+import (
+	"bytes"
+	"log"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestBruteForce(t *testing.T) {
+	// a small U keeps the search short
+	private := &PrivateKey{Set: bigInts(3, 5, 9, 18, 38, 75, 155, 310), V: big.NewInt(13), U: big.NewInt(672)}
+	k, err := NewKnapsackCustom(1, private, private.Set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("Hi")
+	cipher := k.Encrypt(k.NewPlaintext(data))
+
+	logs := new(bytes.Buffer)
+	result, err := BruteForce(cipher, k.Public, BruteForceOptions{MaxKeys: 2, Workers: 4, Logger: log.New(logs, "", 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Keys) != 2 {
+		t.Fatalf("found %d keys, want 2", len(result.Keys))
+	}
+	for _, key := range result.Keys {
+		// any key that encrypts back to cipher decrypts the original data
+		if !bytes.Equal(key.Data, data) {
+			t.Errorf("v=%d u=%d: data = %q, want %q", key.Private.V, key.Private.U, key.Data, data)
+		}
+	}
+
+	if !strings.Contains(logs.String(), "found private key!") {
+		t.Errorf("logs = %q, want the keys found", logs)
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := BruteForce(cipher, PublicKey{}, BruteForceOptions{}); err == nil {
+			t.Error("empty public key: err = nil")
+		}
+		if _, err := BruteForce(Ciphertext{}, k.Public, BruteForceOptions{}); err == nil {
+			t.Error("empty ciphertext: err = nil")
+		}
+	})
+}
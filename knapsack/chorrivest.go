@@ -0,0 +1,395 @@
+package knapsack
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// ErrInvalidCiphertext is returned when a Ciphertext block can't be the encryption of any plaintext block:
+// for ChorRivest it doesn't decrypt to H distinct elements of GF(P), for NaccacheStern to a product of distinct Primes.
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// maxChorRivestP bounds P, so products of coefficients fit in an int64.
+const maxChorRivestP = 1 << 20
+
+// ChorRivestPublicKey is the public half of a ChorRivest key pair.
+// It holds one value per element of GF(P), C[i] = log_G(t + Perm[i]) + D mod P^H - 1.
+// Its density P / log2(P^H) is usually above 1, unlike Merkle–Hellman, so Analyze(PublicKey(C))
+// reports the low density attacks as infeasible.
+type ChorRivestPublicKey struct {
+	P int        `json:"p"`
+	H int        `json:"h"`
+	C []*big.Int `json:"c"`
+}
+
+// ChorRivestPrivateKey is the secret half of a ChorRivest key pair, in GF(P^H) built as GF(P)[t]/F(t).
+type ChorRivestPrivateKey struct {
+	P int `json:"p"`
+	H int `json:"h"`
+
+	// F is the monic irreducible polynomial of degree H with root t, its coefficients from the constant term up.
+	F []int64 `json:"f"`
+
+	// G generates GF(P^H)*, as a polynomial in t of degree < H.
+	G []int64 `json:"g"`
+
+	// Perm scrambles the elements of GF(P): C[i] is built from t + Perm[i]. A nil Perm keeps their order.
+	Perm []int `json:"perm"`
+
+	// D is added to every value of C, between [0, P^H - 1).
+	D *big.Int `json:"d"`
+}
+
+// ChorRivest is a Chor–Rivest key pair, the knapsack cryptosystem over GF(P^H).
+// A block of Bits bits is encoded as a P bit vector with exactly H 1 bits, and encrypted as the sum of the
+// ChorRivestPublicKey's values it selects, modulo P^H - 1.
+// Key generation computes P discrete logarithms, so P^H - 1 must only have small prime factors:
+// Chor and Rivest suggest P=197, H=24 or P=211, H=24.
+type ChorRivest struct {
+	Private *ChorRivestPrivateKey `json:"private"`
+	Public  *ChorRivestPublicKey  `json:"public"`
+}
+
+// chorRivestOrder returns P^H - 1, the modulus of the ciphertext and the logarithms.
+func chorRivestOrder(p, h int) *big.Int {
+	q := new(big.Int).Exp(big.NewInt(int64(p)), big.NewInt(int64(h)), nil)
+	return q.Sub(q, big.NewInt(1))
+}
+
+// chorRivestBits returns the number of bits encrypted per block, floor(log2(C(p, h))).
+func chorRivestBits(p, h int) int {
+	return new(big.Int).Binomial(int64(p), int64(h)).BitLen() - 1
+}
+
+func validateChorRivestParams(p, h int) error {
+	if p < 2 || p > maxChorRivestP || !big.NewInt(int64(p)).ProbablyPrime(20) {
+		return fmt.Errorf("P = %d must be a prime below %d", p, maxChorRivestP)
+	}
+
+	// H = P would leave a single vector with H 1 bits, and no room for a plaintext
+	if h < 2 || h >= p {
+		return fmt.Errorf("H = %d must be between [2, P = %d)", h, p)
+	}
+
+	return nil
+}
+
+// NewChorRivest creates a random ChorRivest key pair over GF(p^h).
+func NewChorRivest(p, h int) (*ChorRivest, error) {
+	return NewChorRivestWithRand(rand.Reader, p, h)
+}
+
+// NewChorRivestWithRand is NewChorRivest, reading its randomness from random.
+func NewChorRivestWithRand(random io.Reader, p, h int) (*ChorRivest, error) {
+	if err := validateChorRivestParams(p, h); err != nil {
+		return nil, err
+	}
+
+	f, err := randomIrreducible(random, int64(p), h)
+	if err != nil {
+		return nil, err
+	}
+	gf := field{p: int64(p), f: f}
+
+	order := chorRivestOrder(p, h)
+	factors := factorize(order)
+
+	// about φ(q-1)/(q-1) of the elements are generators, at least 1 in 10 for the suggested parameters
+	var g poly
+	for !generates(gf, g, factors) {
+		g, err = randomPoly(random, int64(p), h)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	perm, err := randomPerm(random, p)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := rand.Int(random, order)
+	if err != nil {
+		return nil, err
+	}
+
+	private := &ChorRivestPrivateKey{P: p, H: h, F: f, G: g, Perm: perm, D: d}
+	public, err := newChorRivestPublicKey(private, factors)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChorRivest{Private: private, Public: public}, nil
+}
+
+// NewChorRivestPublicKey computes the ChorRivestPublicKey of private.
+func NewChorRivestPublicKey(private *ChorRivestPrivateKey) (*ChorRivestPublicKey, error) {
+	if err := private.Validate(); err != nil {
+		return nil, err
+	}
+
+	return newChorRivestPublicKey(private, factorize(chorRivestOrder(private.P, private.H)))
+}
+
+func newChorRivestPublicKey(private *ChorRivestPrivateKey, factors []primePower) (*ChorRivestPublicKey, error) {
+	gf := private.field()
+	logs, err := newDlog(gf, private.G, factors, private.P)
+	if err != nil {
+		return nil, err
+	}
+
+	// a[α] = log_G(t + α)
+	a := make([]*big.Int, private.P)
+	for alpha := range a {
+		a[alpha], err = logs.log(poly{int64(alpha), 1})
+		if err != nil {
+			return nil, fmt.Errorf("log(t + %d): %w", alpha, err)
+		}
+	}
+
+	c := permute(a, private.Perm)
+	for i := range c {
+		c[i] = new(big.Int).Add(c[i], private.D)
+		c[i].Mod(c[i], logs.order)
+	}
+
+	return &ChorRivestPublicKey{P: private.P, H: private.H, C: c}, nil
+}
+
+func (p *ChorRivestPrivateKey) field() field {
+	return field{p: int64(p.P), f: poly(p.F).trim()}
+}
+
+// Validate checks that P is prime, F is monic and irreducible of degree H, G generates GF(P^H)*,
+// Perm is a permutation of GF(P) and D is between [0, P^H - 1).
+func (p *ChorRivestPrivateKey) Validate() error {
+	if err := validateChorRivestParams(p.P, p.H); err != nil {
+		return err
+	}
+
+	for _, a := range [][]int64{p.F, p.G} {
+		for _, c := range a {
+			if c < 0 || c >= int64(p.P) {
+				return fmt.Errorf("coefficient %d is not in GF(%d)", c, p.P)
+			}
+		}
+	}
+
+	f := poly(p.F).trim()
+	if f.degree() != p.H || f[p.H] != 1 || !irreducible(f, int64(p.P)) {
+		return fmt.Errorf("F = %v is not a monic irreducible polynomial of degree %d", p.F, p.H)
+	}
+
+	order := chorRivestOrder(p.P, p.H)
+	g := poly(p.G).trim()
+	if g.degree() >= p.H || !generates(p.field(), g, factorize(order)) {
+		return fmt.Errorf("G = %v does not generate GF(%d^%d)*", p.G, p.P, p.H)
+	}
+
+	if err := validatePerm(p.Perm, p.P); err != nil {
+		return err
+	}
+
+	if p.D == nil || p.D.Sign() < 0 || p.D.Cmp(order) != -1 {
+		return fmt.Errorf("D = %v is not between [0, %v)", p.D, order)
+	}
+
+	return nil
+}
+
+// Validate checks that P and H are valid parameters and that C holds P values between [0, P^H - 1).
+func (p *ChorRivestPublicKey) Validate() error {
+	if err := validateChorRivestParams(p.P, p.H); err != nil {
+		return err
+	}
+
+	if len(p.C) != p.P {
+		return fmt.Errorf("C has %d values, want P = %d", len(p.C), p.P)
+	}
+
+	order := chorRivestOrder(p.P, p.H)
+	for i, c := range p.C {
+		if c == nil || c.Sign() < 0 || c.Cmp(order) != -1 {
+			return fmt.Errorf("C[%d] = %v is not between [0, %v)", i, c, order)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that k.Private and k.Public are valid and that k.Public was derived from k.Private:
+// G^(C[i] - D) = t + Perm[i] for every i, without computing any discrete logarithm.
+func (k *ChorRivest) Validate() error {
+	if err := k.Private.Validate(); err != nil {
+		return err
+	}
+	if err := k.Public.Validate(); err != nil {
+		return err
+	}
+
+	if k.Public.P != k.Private.P || k.Public.H != k.Private.H {
+		return fmt.Errorf("%w: public P = %d, H = %d, private P = %d, H = %d",
+			ErrKeyMismatch, k.Public.P, k.Public.H, k.Private.P, k.Private.H)
+	}
+
+	gf := k.Private.field()
+	order := chorRivestOrder(k.Private.P, k.Private.H)
+	for i, c := range k.Public.C {
+		alpha := i
+		if k.Private.Perm != nil {
+			alpha = k.Private.Perm[i]
+		}
+
+		e := new(big.Int).Sub(c, k.Private.D)
+		if gf.exp(k.Private.G, e.Mod(e, order)).trim().key() != poly([]int64{int64(alpha), 1}).trim().key() {
+			return fmt.Errorf("%w: C[%d] = %v is not log_G(t + %d) + D", ErrKeyMismatch, i, c, alpha)
+		}
+	}
+
+	return nil
+}
+
+// Bits returns the number of bits encrypted per block.
+func (p *ChorRivestPublicKey) Bits() int {
+	return chorRivestBits(p.P, p.H)
+}
+
+// Bits returns the number of bits encrypted per block.
+func (p *ChorRivestPrivateKey) Bits() int {
+	return chorRivestBits(p.P, p.H)
+}
+
+// Encrypt encrypts plain using only the ChorRivestPublicKey.
+// Each block is treated as exactly Bits bits, bits above that width are ignored.
+func (p *ChorRivestPublicKey) Encrypt(plain Plaintext) Ciphertext {
+	order := chorRivestOrder(p.P, p.H)
+	mask := new(big.Int).Lsh(big.NewInt(1), uint(p.Bits()))
+	mask.Sub(mask, big.NewInt(1))
+
+	cipher := make(Ciphertext, 0, len(plain))
+	for _, block := range plain {
+		m := new(big.Int).And(block, mask)
+
+		sum := big.NewInt(0)
+		for i, bit := range constantWeight(m, p.P, p.H) {
+			if bit {
+				sum.Add(sum, p.C[i])
+			}
+		}
+
+		cipher = append(cipher, sum.Mod(sum, order))
+	}
+
+	return cipher
+}
+
+// Decrypt decrypts cipher using only the ChorRivestPrivateKey.
+// G^(c - H*D) is the product of the H values t + α selected by the block, so adding F to it
+// gives a polynomial whose roots are the -α.
+func (p *ChorRivestPrivateKey) Decrypt(cipher Ciphertext) (Plaintext, error) {
+	gf := p.field()
+	order := chorRivestOrder(p.P, p.H)
+	hd := new(big.Int).Mul(big.NewInt(int64(p.H)), p.D)
+
+	// index[α] is the position of the public value built from t + α
+	index := make([]int, p.P)
+	for i := range index {
+		index[i] = i
+	}
+	for i, alpha := range p.Perm {
+		index[alpha] = i
+	}
+
+	plain := make(Plaintext, 0, len(cipher))
+	for b, block := range cipher {
+		r := new(big.Int).Sub(block, hd)
+		u := gf.exp(p.G, r.Mod(r, order))
+
+		// s(t) = u(t) + F(t), monic of degree H
+		s := make(poly, p.H+1)
+		copy(s, u)
+		for i, fi := range gf.f {
+			s[i] = (s[i] + fi) % gf.p
+		}
+
+		bits := make([]bool, p.P)
+		roots := 0
+		for alpha := range p.P {
+			if s.eval((gf.p-int64(alpha))%gf.p, gf.p) == 0 {
+				bits[index[alpha]] = true
+				roots++
+			}
+		}
+		if roots != p.H {
+			return nil, fmt.Errorf("%w: block %d has %d roots, want %d", ErrInvalidCiphertext, b, roots, p.H)
+		}
+
+		plain = append(plain, fromConstantWeight(bits, p.H))
+	}
+
+	return plain, nil
+}
+
+// constantWeight encodes m between [0, C(n, k)) as n bits with exactly k set, ranked by the combinatorial number system.
+func constantWeight(m *big.Int, n, k int) []bool {
+	m = new(big.Int).Set(m)
+	bits := make([]bool, n)
+
+	for i := 0; i < n && k > 0; i++ {
+		// the vectors with bit i clear come first
+		b := new(big.Int).Binomial(int64(n-1-i), int64(k))
+		if m.Cmp(b) >= 0 {
+			bits[i] = true
+			m.Sub(m, b)
+			k--
+		}
+	}
+
+	return bits
+}
+
+// fromConstantWeight is the inverse of constantWeight.
+func fromConstantWeight(bits []bool, k int) *big.Int {
+	n := len(bits)
+	m := big.NewInt(0)
+
+	for i, bit := range bits {
+		if bit {
+			m.Add(m, new(big.Int).Binomial(int64(n-1-i), int64(k)))
+			k--
+		}
+	}
+
+	return m
+}
+
+// Bits returns the number of bits encrypted per block, from whichever half of the key pair is set, or 0 for neither.
+func (k *ChorRivest) Bits() int {
+	switch {
+	case k.Public != nil:
+		return k.Public.Bits()
+	case k.Private != nil:
+		return k.Private.Bits()
+	default:
+		return 0
+	}
+}
+
+func (k *ChorRivest) Encrypt(plain Plaintext) Ciphertext {
+	return k.Public.Encrypt(plain)
+}
+
+func (k *ChorRivest) Decrypt(cipher Ciphertext) (Plaintext, error) {
+	return k.Private.Decrypt(cipher)
+}
+
+func (k *ChorRivest) NewPlaintext(data []byte) Plaintext {
+	return NewPlaintextBits(k.Bits(), data)
+}
+
+func (k *ChorRivest) FromPlaintext(plain Plaintext) ([]byte, error) {
+	return FromPlaintextBits(k.Bits(), plain)
+}
@@ -0,0 +1,182 @@
+package knapsack
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestChorRivest(t *testing.T) {
+	t.Run("random", func(t *testing.T) {
+		for _, ph := range [][2]int{{5, 2}, {13, 4}, {31, 5}, {53, 6}, {103, 12}} {
+			p, h := ph[0], ph[1]
+
+			k, err := NewChorRivestWithRand(NewSeededRand([]byte("chor-rivest")), p, h)
+			if err != nil {
+				t.Fatalf("p=%d, h=%d: %v", p, h, err)
+			}
+			if err := k.Private.Validate(); err != nil {
+				t.Fatalf("p=%d, h=%d: %v", p, h, err)
+			}
+
+			data := []byte("Hello World!")
+			plain, err := k.Decrypt(k.Encrypt(k.NewPlaintext(data)))
+			if err != nil {
+				t.Fatalf("p=%d, h=%d: %v", p, h, err)
+			}
+
+			got, err := k.FromPlaintext(plain)
+			if err != nil {
+				t.Fatalf("p=%d, h=%d: %v", p, h, err)
+			}
+			if string(got) != string(data) {
+				t.Errorf("p=%d, h=%d: got %q, want %q", p, h, got, data)
+			}
+
+			// G^(C[i] - D) = t + Perm[i]
+			gf := k.Private.field()
+			order := chorRivestOrder(p, h)
+			for i, c := range k.Public.C {
+				e := new(big.Int).Sub(c, k.Private.D)
+				got := gf.exp(k.Private.G, e.Mod(e, order))
+				if want := (poly{int64(k.Private.Perm[i]), 1}).trim(); got.key() != want.key() {
+					t.Fatalf("p=%d, h=%d: G^(C[%d] - D) = %v, want %v", p, h, i, got, want)
+				}
+			}
+
+			public, err := NewChorRivestPublicKey(k.Private)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if BigIntsToStr(public.C) != BigIntsToStr(k.Public.C) {
+				t.Errorf("p=%d, h=%d: NewChorRivestPublicKey = %s, want %s", p, h, BigIntsToStr(public.C), BigIntsToStr(k.Public.C))
+			}
+		}
+	})
+
+	t.Run("suggested", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("computes 197 discrete logarithms in GF(197^24)")
+		}
+
+		k, err := NewChorRivestWithRand(NewSeededRand([]byte("chor-rivest")), 197, 24)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data := []byte("Chor–Rivest encrypts about 101 bits per block")
+		plain, err := k.Decrypt(k.Encrypt(k.NewPlaintext(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := k.FromPlaintext(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(data) {
+			t.Errorf("got %q, want %q", got, data)
+		}
+
+		// unlike Merkle–Hellman, the knapsack is too dense for the low density attacks
+		a := Analyze(PublicKey(k.Public.C))
+		if a.Density <= 1 || a.LagariasOdlyzko || a.CJLOSS {
+			t.Errorf("density = %v, want above 1 and out of reach of the lattice attacks", a.Density)
+		}
+	})
+
+	t.Run("invalid ciphertext", func(t *testing.T) {
+		k, err := NewChorRivestWithRand(NewSeededRand([]byte("chor-rivest")), 13, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// only sums of exactly H public values decrypt, almost nothing else does
+		failed := 0
+		for c := range 20 {
+			if _, err := k.Decrypt(Ciphertext{big.NewInt(int64(c))}); errors.Is(err, ErrInvalidCiphertext) {
+				failed++
+			}
+		}
+		if failed == 0 {
+			t.Errorf("every ciphertext 0 to 19 decrypted")
+		}
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for _, ph := range [][2]int{{12, 4}, {1, 1}, {13, 1}, {13, 13}, {13, 20}, {1<<20 + 7, 2}} {
+			if _, err := NewChorRivest(ph[0], ph[1]); err == nil {
+				t.Errorf("p=%d, h=%d: err = nil", ph[0], ph[1])
+			}
+		}
+	})
+
+	t.Run("invalid private key", func(t *testing.T) {
+		valid := func() *ChorRivestPrivateKey {
+			// x^2 + 2 is irreducible over GF(5), and x + 1 has order 24
+			return &ChorRivestPrivateKey{P: 5, H: 2, F: []int64{2, 0, 1}, G: []int64{1, 1}, D: big.NewInt(7)}
+		}
+		if err := valid().Validate(); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name   string
+			modify func(p *ChorRivestPrivateKey)
+		}{
+			{name: "reducible f", modify: func(p *ChorRivestPrivateKey) { p.F = []int64{1, 0, 1} }},
+			{name: "f not monic", modify: func(p *ChorRivestPrivateKey) { p.F = []int64{2, 0, 2} }},
+			{name: "f wrong degree", modify: func(p *ChorRivestPrivateKey) { p.H = 3 }},
+			{name: "coefficient out of range", modify: func(p *ChorRivestPrivateKey) { p.G = []int64{7, 1} }},
+			{name: "g not a generator", modify: func(p *ChorRivestPrivateKey) { p.G = []int64{4} }},
+			{name: "g zero", modify: func(p *ChorRivestPrivateKey) { p.G = nil }},
+			{name: "d too large", modify: func(p *ChorRivestPrivateKey) { p.D = big.NewInt(24) }},
+			{name: "d nil", modify: func(p *ChorRivestPrivateKey) { p.D = nil }},
+			{name: "perm", modify: func(p *ChorRivestPrivateKey) { p.Perm = []int{0, 1, 2, 3, 3} }},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				p := valid()
+				tt.modify(p)
+				if err := p.Validate(); err == nil {
+					t.Errorf("err = nil")
+				}
+				if _, err := NewChorRivestPublicKey(p); err == nil {
+					t.Errorf("NewChorRivestPublicKey err = nil")
+				}
+			})
+		}
+	})
+}
+
+func TestConstantWeight(t *testing.T) {
+	n, k := 9, 4
+	count := new(big.Int).Binomial(int64(n), int64(k)).Int64()
+
+	seen := map[string]bool{}
+	for m := range count {
+		bits := constantWeight(big.NewInt(m), n, k)
+
+		key, weight := "", 0
+		for _, b := range bits {
+			if b {
+				key += "1"
+				weight++
+			} else {
+				key += "0"
+			}
+		}
+
+		if weight != k {
+			t.Errorf("%d encodes to %s, want %d 1 bits", m, key, k)
+		}
+		if seen[key] {
+			t.Errorf("%d encodes to %s twice", m, key)
+		}
+		seen[key] = true
+
+		if got := fromConstantWeight(bits, k); got.Int64() != m {
+			t.Errorf("%s decodes to %v, want %d", key, got, m)
+		}
+	}
+}
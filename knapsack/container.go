@@ -0,0 +1,178 @@
+package knapsack
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ContainerHeader describes the Ciphertext stored in a container.
+//
+// A container is the header followed by the blocks written by an EncryptWriter:
+// "KNAP" | version byte | kind byte | uvarint Bits | uvarint Blocks | blocks...
+type ContainerHeader struct {
+	Bits   int
+	Blocks uint64
+}
+
+// ErrBlockCount is returned when a container holds a different number of blocks than its header says.
+var ErrBlockCount = errors.New("block count does not match container header")
+
+// containerBlocks returns the number of n bit blocks needed to encrypt size bytes, including padding.
+func containerBlocks(n int, size int64) uint64 {
+	// the padding adds at least 1 bit
+	return 8*uint64(size)/uint64(n) + 1
+}
+
+// WriteContainerHeader writes h to w.
+func WriteContainerHeader(w io.Writer, h ContainerHeader) error {
+	buf := append([]byte{}, binaryMagic...)
+	buf = append(buf, encodingVersion, byte(kindContainer))
+	buf = binary.AppendUvarint(buf, uint64(h.Bits))
+	buf = binary.AppendUvarint(buf, h.Blocks)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadContainerHeader reads a header written by WriteContainerHeader.
+func ReadContainerHeader(r io.ByteReader) (ContainerHeader, error) {
+	header := make([]byte, len(binaryMagic)+2)
+	for i := range header {
+		b, err := r.ReadByte()
+		if err != nil {
+			return ContainerHeader{}, fmt.Errorf("%w: missing header", ErrInvalidEncoding)
+		}
+		header[i] = b
+	}
+
+	if string(header[:len(binaryMagic)]) != string(binaryMagic) {
+		return ContainerHeader{}, fmt.Errorf("%w: missing header", ErrInvalidEncoding)
+	}
+	version := int(header[len(binaryMagic)])
+	if err := checkHeader(version, kind(header[len(binaryMagic)+1]), kindContainer); err != nil {
+		return ContainerHeader{}, err
+	}
+
+	bits, err := binary.ReadUvarint(r)
+	if err != nil || bits > math.MaxInt32/8 {
+		return ContainerHeader{}, fmt.Errorf("%w: invalid bits", ErrInvalidEncoding)
+	}
+
+	blocks, err := binary.ReadUvarint(r)
+	if err != nil {
+		return ContainerHeader{}, fmt.Errorf("%w: invalid block count", ErrInvalidEncoding)
+	}
+
+	return ContainerHeader{Bits: headerBits(version, int(bits)), Blocks: blocks}, nil
+}
+
+// EncryptContainer encrypts exactly size bytes read from r into a container written to w.
+func EncryptContainer(public PublicKey, w io.Writer, r io.Reader, size int64) error {
+	return encryptContainer(public, w, r, size)
+}
+
+// EncryptCryptosystemContainer is EncryptContainer with the public key of any Cryptosystem.
+func EncryptCryptosystemContainer(c Cryptosystem, w io.Writer, r io.Reader, size int64) error {
+	return encryptContainer(c, w, r, size)
+}
+
+func encryptContainer(public blockEncrypter, w io.Writer, r io.Reader, size int64) error {
+	// a key can be built in memory without being decoded, so it may have no bits
+	if err := validateBits(public.Bits()); err != nil {
+		return err
+	}
+	if size < 0 {
+		return fmt.Errorf("negative size %d", size)
+	}
+
+	h := ContainerHeader{
+		Bits:   public.Bits(),
+		Blocks: containerBlocks(public.Bits(), size),
+	}
+	if err := WriteContainerHeader(w, h); err != nil {
+		return err
+	}
+
+	e := newEncryptWriter(public, w)
+	if _, err := io.CopyN(e, r, size); err != nil {
+		return err
+	}
+
+	return e.Close()
+}
+
+// DecryptContainer decrypts a container written by EncryptContainer from r into w.
+// ErrBlockCount is returned if the container was truncated or extended.
+func DecryptContainer(private *PrivateKey, w io.Writer, r io.Reader) error {
+	if err := validateBits(private.Bits()); err != nil {
+		return err
+	}
+
+	return decryptContainer(private, maxBlockLen(private.Bits(), private.modulus()), w, r)
+}
+
+// DecryptCryptosystemContainer is DecryptContainer with the private key of any Cryptosystem.
+// c must hold both halves of the key pair, as read by ReadCryptosystemPrivateKeyPEM:
+// the public key bounds the length of a block.
+func DecryptCryptosystemContainer(c Cryptosystem, w io.Writer, r io.Reader) error {
+	// an empty Cryptosystem has no PublicParams either
+	if err := validateBits(c.Bits()); err != nil {
+		return err
+	}
+
+	return decryptContainer(c, maxCiphertextLen(c.PublicParams()), w, r)
+}
+
+// decryptContainer decrypts a container with private, rejecting Ciphertext blocks longer than maxLen bytes.
+func decryptContainer(private blockDecrypter, maxLen int, w io.Writer, r io.Reader) error {
+	br := bufio.NewReader(r)
+
+	h, err := ReadContainerHeader(br)
+	if err != nil {
+		return err
+	}
+
+	if h.Bits != private.Bits() {
+		return fmt.Errorf("container has %d bit blocks, private key has %d bits", h.Bits, private.Bits())
+	}
+
+	d := newDecryptReader(private, maxLen, br)
+	if _, err := io.Copy(w, d); err != nil {
+		return err
+	}
+
+	if d.Blocks() != h.Blocks {
+		return fmt.Errorf("%w: read %d blocks, want %d", ErrBlockCount, d.Blocks(), h.Blocks)
+	}
+
+	return nil
+}
+
+// ReadContainer reads the Ciphertext of a container written by EncryptContainer for public.
+func ReadContainer(public PublicKey, r io.Reader) (ContainerHeader, Ciphertext, error) {
+	br := bufio.NewReader(r)
+
+	h, err := ReadContainerHeader(br)
+	if err != nil {
+		return ContainerHeader{}, nil, err
+	}
+
+	if h.Bits != public.Bits() {
+		return ContainerHeader{}, nil, fmt.Errorf("container has %d bit blocks, public key has %d bits", h.Bits, public.Bits())
+	}
+
+	cipher, err := ReadCiphertext(public, br)
+	if err != nil {
+		return ContainerHeader{}, nil, err
+	}
+
+	if uint64(len(cipher)) != h.Blocks {
+		return ContainerHeader{}, nil, fmt.Errorf("%w: read %d blocks, want %d", ErrBlockCount, len(cipher), h.Blocks)
+	}
+
+	return h, cipher, nil
+}
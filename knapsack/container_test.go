@@ -0,0 +1,120 @@
+package knapsack
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	mathRand "math/rand/v2"
+	"testing"
+)
+
+func TestContainer(t *testing.T) {
+	t.Run("random", func(t *testing.T) {
+		for range 50 {
+			n := mathRand.IntN(70) + 1
+
+			k, err := NewKnapsackBits(n, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data := make([]byte, mathRand.IntN(100))
+			_, _ = rand.Read(data)
+
+			buf := new(bytes.Buffer)
+			if err := EncryptContainer(k.Public, buf, bytes.NewReader(data), int64(len(data))); err != nil {
+				t.Fatal(err)
+			}
+			container := buf.Bytes()
+
+			h, cipher, err := ReadContainer(k.Public, bytes.NewReader(container))
+			if err != nil {
+				t.Fatal(err)
+			}
+			blocks := 8*len(data)/n + 1
+			if h.Bits != n || h.Blocks != uint64(blocks) || len(cipher) != blocks {
+				t.Errorf("header = %+v with %d blocks, want %d bits and %d blocks", h, len(cipher), n, blocks)
+			}
+
+			got := new(bytes.Buffer)
+			if err := DecryptContainer(k.Private, got, bytes.NewReader(container)); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got.Bytes(), data) {
+				t.Errorf("got %#v, want %#v", got.Bytes(), data)
+			}
+		}
+	})
+}
+
+func TestContainerInvalid(t *testing.T) {
+	k, err := NewKnapsack(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("Hello World!")
+
+	buf := new(bytes.Buffer)
+	if err := EncryptContainer(k.Public, buf, bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	container := buf.Bytes()
+
+	t.Run("short input", func(t *testing.T) {
+		if err := EncryptContainer(k.Public, new(bytes.Buffer), bytes.NewReader(data), int64(len(data))+1); err == nil {
+			t.Error("want error")
+		}
+	})
+
+	t.Run("extra block", func(t *testing.T) {
+		// append a copy of the padded last block, so the stream itself is still valid
+		last := k.Encrypt(k.NewPlaintext(nil))[0]
+		extended := appendBlock(bytes.Clone(container), last)
+
+		if err := DecryptContainer(k.Private, new(bytes.Buffer), bytes.NewReader(extended)); !errors.Is(err, ErrBlockCount) {
+			t.Errorf("err = %v, want %v", err, ErrBlockCount)
+		}
+		if _, _, err := ReadContainer(k.Public, bytes.NewReader(extended)); !errors.Is(err, ErrBlockCount) {
+			t.Errorf("err = %v, want %v", err, ErrBlockCount)
+		}
+	})
+
+	t.Run("not a container", func(t *testing.T) {
+		public, _ := k.Public.MarshalBinary()
+
+		if err := DecryptContainer(k.Private, new(bytes.Buffer), bytes.NewReader(public)); !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("err = %v, want %v", err, ErrInvalidEncoding)
+		}
+	})
+
+	t.Run("wrong block size", func(t *testing.T) {
+		k2, err := NewKnapsack(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := DecryptContainer(k2.Private, new(bytes.Buffer), bytes.NewReader(container)); err == nil {
+			t.Error("want error")
+		}
+	})
+
+	t.Run("no bits", func(t *testing.T) {
+		// keys built in memory, never decoded
+		if err := EncryptContainer(PublicKey{}, new(bytes.Buffer), bytes.NewReader(data), int64(len(data))); err == nil {
+			t.Error("EncryptContainer: want error")
+		}
+		if err := DecryptContainer(new(PrivateKey), new(bytes.Buffer), bytes.NewReader(container)); err == nil {
+			t.Error("DecryptContainer: want error")
+		}
+		for _, c := range []Cryptosystem{new(Knapsack), new(ChorRivest), new(NaccacheStern)} {
+			if err := EncryptCryptosystemContainer(c, new(bytes.Buffer), bytes.NewReader(data), int64(len(data))); err == nil {
+				t.Errorf("EncryptCryptosystemContainer(%s): want error", c.Scheme())
+			}
+			if err := DecryptCryptosystemContainer(c, new(bytes.Buffer), bytes.NewReader(container)); err == nil {
+				t.Errorf("DecryptCryptosystemContainer(%s): want error", c.Scheme())
+			}
+		}
+	})
+}
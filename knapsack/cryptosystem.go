@@ -0,0 +1,303 @@
+package knapsack
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+)
+
+// Names of the schemes registered with NewCryptosystem.
+const (
+	SchemeMerkleHellman = "merkle-hellman"
+	SchemeChorRivest    = "chor-rivest"
+	SchemeNaccacheStern = "naccache-stern"
+)
+
+// Cryptosystem is a key pair of one of the knapsack schemes, so code written against it works with any of them.
+// *Knapsack, *ChorRivest and *NaccacheStern implement it, NewCryptosystem picks one by name.
+// Encrypt and PublicParams need the public key, Decrypt the private key: the empty Cryptosystem of NewCryptosystem
+// has neither until GenerateKey, UnmarshalPublicKey or UnmarshalPrivateKey fills it.
+type Cryptosystem interface {
+	// Scheme returns the name the Cryptosystem is registered under.
+	Scheme() string
+
+	// GenerateKey replaces the key pair with a random one encrypting blocks of at least bits bits,
+	// reading its randomness from random, or crypto/rand.Reader if nil.
+	GenerateKey(random io.Reader, bits int) error
+
+	// Bits returns the number of bits encrypted per block.
+	Bits() int
+
+	Encrypt(plain Plaintext) Ciphertext
+	Decrypt(cipher Ciphertext) (Plaintext, error)
+
+	// PublicParams returns everything the public key reveals.
+	PublicParams() PublicParams
+
+	// MarshalPublicKey encodes the public key, MarshalPrivateKey the whole key pair.
+	MarshalPublicKey() ([]byte, error)
+	MarshalPrivateKey() ([]byte, error)
+
+	// UnmarshalPublicKey replaces the key pair with just the public key encoded by MarshalPublicKey, enough to Encrypt.
+	UnmarshalPublicKey(data []byte) error
+
+	// UnmarshalPrivateKey replaces the key pair with the one encoded by MarshalPrivateKey, checking that it is valid.
+	UnmarshalPrivateKey(data []byte) error
+}
+
+// PublicParams is the public key of any Cryptosystem, in the form the attacks work on:
+// a Ciphertext block combines the Values its plaintext selects.
+type PublicParams struct {
+	Scheme string
+	Bits   int
+
+	// Values are the public knapsack.
+	Values []*big.Int
+
+	// Modulus reduces every Ciphertext block, nil if blocks are plain sums.
+	Modulus *big.Int
+
+	// Multiplicative is set if a block is the product of the selected Values instead of their sum.
+	Multiplicative bool
+}
+
+var schemes = map[string]func() Cryptosystem{
+	SchemeMerkleHellman: func() Cryptosystem { return new(Knapsack) },
+	SchemeChorRivest:    func() Cryptosystem { return new(ChorRivest) },
+	SchemeNaccacheStern: func() Cryptosystem { return new(NaccacheStern) },
+}
+
+// Schemes returns the name of every registered scheme, sorted.
+func Schemes() []string {
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// NewCryptosystem returns an empty key pair of the scheme name, call GenerateKey to fill it.
+func NewCryptosystem(name string) (Cryptosystem, error) {
+	newScheme, ok := schemes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scheme %q, want one of %v", name, Schemes())
+	}
+
+	return newScheme(), nil
+}
+
+func (k *Knapsack) Scheme() string {
+	return SchemeMerkleHellman
+}
+
+// GenerateKey replaces k with a random Knapsack encrypting blocks of bits bits, see NewKnapsackBits.
+func (k *Knapsack) GenerateKey(random io.Reader, bits int) error {
+	generated, err := NewKnapsackBits(bits, Options{Rand: random})
+	if err != nil {
+		return err
+	}
+
+	*k = *generated
+	return nil
+}
+
+func (k *Knapsack) PublicParams() PublicParams {
+	return PublicParams{Scheme: k.Scheme(), Bits: k.Bits(), Values: k.Public}
+}
+
+// MarshalPublicKey returns the binary encoding of the PublicKey, see PublicKey.MarshalBinary.
+func (k *Knapsack) MarshalPublicKey() ([]byte, error) {
+	return k.Public.MarshalBinary()
+}
+
+// MarshalPrivateKey returns the binary encoding of the PrivateKey, which the PublicKey is built from.
+func (k *Knapsack) MarshalPrivateKey() ([]byte, error) {
+	return k.Private.MarshalBinary()
+}
+
+func (k *Knapsack) UnmarshalPublicKey(data []byte) error {
+	var public PublicKey
+	if err := public.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	if err := public.Validate(); err != nil {
+		return err
+	}
+
+	*k = Knapsack{Public: public}
+	return nil
+}
+
+func (k *Knapsack) UnmarshalPrivateKey(data []byte) error {
+	private := new(PrivateKey)
+	if err := private.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	if err := private.Validate(); err != nil {
+		return err
+	}
+
+	*k = Knapsack{Private: private, Public: NewPublicKey(private, private.Set)}
+	return nil
+}
+
+// chorRivestParams are P and H with only small prime factors in P^H - 1, by increasing Bits.
+var chorRivestParams = [][2]int{{13, 4}, {31, 5}, {53, 6}, {103, 12}, {197, 24}, {211, 24}}
+
+func (k *ChorRivest) Scheme() string {
+	return SchemeChorRivest
+}
+
+// GenerateKey replaces k with a random ChorRivest key pair using the smallest of
+// P=13, H=4, P=31, H=5, P=53, H=6, P=103, H=12, P=197, H=24 or P=211, H=24 that encrypts at least bits bits per block.
+// Use NewChorRivestWithRand for other parameters.
+func (k *ChorRivest) GenerateKey(random io.Reader, bits int) error {
+	if random == nil {
+		random = rand.Reader
+	}
+
+	for _, ph := range chorRivestParams {
+		if chorRivestBits(ph[0], ph[1]) < bits {
+			continue
+		}
+
+		generated, err := NewChorRivestWithRand(random, ph[0], ph[1])
+		if err != nil {
+			return err
+		}
+
+		*k = *generated
+		return nil
+	}
+
+	last := chorRivestParams[len(chorRivestParams)-1]
+	return fmt.Errorf("bits = %d, the largest parameters encrypt %d", bits, chorRivestBits(last[0], last[1]))
+}
+
+func (k *ChorRivest) PublicParams() PublicParams {
+	return PublicParams{
+		Scheme:  k.Scheme(),
+		Bits:    k.Bits(),
+		Values:  k.Public.C,
+		Modulus: chorRivestOrder(k.Public.P, k.Public.H),
+	}
+}
+
+// MarshalPublicKey returns the JSON of the ChorRivestPublicKey.
+func (k *ChorRivest) MarshalPublicKey() ([]byte, error) {
+	return json.Marshal(k.Public)
+}
+
+// MarshalPrivateKey returns the JSON of the whole key pair,
+// since computing the ChorRivestPublicKey again takes P discrete logarithms.
+func (k *ChorRivest) MarshalPrivateKey() ([]byte, error) {
+	return json.Marshal(k)
+}
+
+func (k *ChorRivest) UnmarshalPublicKey(data []byte) error {
+	public := new(ChorRivestPublicKey)
+	if err := json.Unmarshal(data, public); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+
+	if err := public.Validate(); err != nil {
+		return err
+	}
+
+	*k = ChorRivest{Public: public}
+	return nil
+}
+
+func (k *ChorRivest) UnmarshalPrivateKey(data []byte) error {
+	pair := new(ChorRivest)
+	if err := json.Unmarshal(data, pair); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	if pair.Private == nil || pair.Public == nil {
+		return fmt.Errorf("%w: missing private or public key", ErrInvalidEncoding)
+	}
+
+	if err := pair.Validate(); err != nil {
+		return err
+	}
+
+	*k = *pair
+	return nil
+}
+
+func (k *NaccacheStern) Scheme() string {
+	return SchemeNaccacheStern
+}
+
+// GenerateKey replaces k with a random NaccacheStern key pair encrypting blocks of bits bits, see NewNaccacheStern.
+func (k *NaccacheStern) GenerateKey(random io.Reader, bits int) error {
+	if random == nil {
+		random = rand.Reader
+	}
+
+	generated, err := NewNaccacheSternWithRand(random, bits)
+	if err != nil {
+		return err
+	}
+
+	*k = *generated
+	return nil
+}
+
+func (k *NaccacheStern) PublicParams() PublicParams {
+	return PublicParams{
+		Scheme:         k.Scheme(),
+		Bits:           k.Bits(),
+		Values:         k.Public.V,
+		Modulus:        k.Public.P,
+		Multiplicative: true,
+	}
+}
+
+// MarshalPublicKey returns the JSON of the NaccacheSternPublicKey.
+func (k *NaccacheStern) MarshalPublicKey() ([]byte, error) {
+	return json.Marshal(k.Public)
+}
+
+// MarshalPrivateKey returns the JSON of the whole key pair.
+func (k *NaccacheStern) MarshalPrivateKey() ([]byte, error) {
+	return json.Marshal(k)
+}
+
+func (k *NaccacheStern) UnmarshalPublicKey(data []byte) error {
+	public := new(NaccacheSternPublicKey)
+	if err := json.Unmarshal(data, public); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+
+	if err := public.Validate(); err != nil {
+		return err
+	}
+
+	*k = NaccacheStern{Public: public}
+	return nil
+}
+
+func (k *NaccacheStern) UnmarshalPrivateKey(data []byte) error {
+	pair := new(NaccacheStern)
+	if err := json.Unmarshal(data, pair); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	if pair.Private == nil || pair.Public == nil {
+		return fmt.Errorf("%w: missing private or public key", ErrInvalidEncoding)
+	}
+
+	if err := pair.Validate(); err != nil {
+		return err
+	}
+
+	*k = *pair
+	return nil
+}
@@ -0,0 +1,202 @@
+package knapsack
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// every scheme must satisfy Cryptosystem
+var (
+	_ Cryptosystem = (*Knapsack)(nil)
+	_ Cryptosystem = (*ChorRivest)(nil)
+	_ Cryptosystem = (*NaccacheStern)(nil)
+)
+
+func TestCryptosystem(t *testing.T) {
+	if got, want := Schemes(), []string{SchemeChorRivest, SchemeMerkleHellman, SchemeNaccacheStern}; !slices.Equal(got, want) {
+		t.Errorf("Schemes() = %v, want %v", got, want)
+	}
+
+	for _, name := range Schemes() {
+		t.Run(name, func(t *testing.T) {
+			for _, bits := range []int{1, 8, 20, 50} {
+				c, err := NewCryptosystem(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := c.GenerateKey(NewSeededRand([]byte(name)), bits); err != nil {
+					t.Fatalf("bits=%d: %v", bits, err)
+				}
+				if c.Scheme() != name {
+					t.Errorf("Scheme() = %q, want %q", c.Scheme(), name)
+				}
+				if c.Bits() < bits {
+					t.Errorf("Bits() = %d, want at least %d", c.Bits(), bits)
+				}
+
+				data := []byte("Hello World!")
+				plain, err := c.Decrypt(c.Encrypt(NewPlaintextBits(c.Bits(), data)))
+				if err != nil {
+					t.Fatalf("bits=%d: %v", bits, err)
+				}
+				got, err := FromPlaintextBits(c.Bits(), plain)
+				if err != nil {
+					t.Fatalf("bits=%d: %v", bits, err)
+				}
+				if string(got) != string(data) {
+					t.Errorf("bits=%d: got %q, want %q", bits, got, data)
+				}
+
+				params := c.PublicParams()
+				if params.Scheme != name || params.Bits != c.Bits() || len(params.Values) == 0 {
+					t.Errorf("bits=%d: PublicParams() = %+v", bits, params)
+				}
+			}
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := NewCryptosystem("rsa"); err == nil {
+			t.Errorf("err = nil")
+		}
+	})
+
+	t.Run("too many bits", func(t *testing.T) {
+		if err := new(ChorRivest).GenerateKey(nil, 105); err == nil {
+			t.Errorf("err = nil")
+		}
+	})
+}
+
+func TestCryptosystemPEM(t *testing.T) {
+	for _, name := range Schemes() {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewCryptosystem(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Bits() != 0 {
+				t.Errorf("empty Bits() = %d, want 0", c.Bits())
+			}
+			if err := c.GenerateKey(NewSeededRand([]byte(name)), 16); err != nil {
+				t.Fatal(err)
+			}
+
+			buf := new(bytes.Buffer)
+			if err := WriteCryptosystemPublicKeyPEM(buf, c); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(buf.String(), "Scheme: "); got != (name != SchemeMerkleHellman) {
+				t.Errorf("Scheme header = %v:\n%s", got, buf)
+			}
+			public, err := ReadCryptosystemPublicKeyPEM(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if public.Scheme() != name || public.Bits() != c.Bits() {
+				t.Errorf("public key = %s with %d bits, want %s with %d bits", public.Scheme(), public.Bits(), name, c.Bits())
+			}
+
+			buf.Reset()
+			if err := WriteCryptosystemPrivateKeyPEM(buf, c); err != nil {
+				t.Fatal(err)
+			}
+			private, err := ReadCryptosystemPrivateKeyPEM(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf.Reset()
+			if err := WriteEncryptedCryptosystemPrivateKeyPEM(buf, c, "hunter2"); err != nil {
+				t.Fatal(err)
+			}
+			encrypted := buf.Bytes()
+			if _, err := ReadEncryptedCryptosystemPrivateKeyPEM(bytes.NewReader(encrypted), "hunter3"); !errors.Is(err, ErrIncorrectPassphrase) {
+				t.Errorf("err = %v, want %v", err, ErrIncorrectPassphrase)
+			}
+			decrypted, err := ReadEncryptedCryptosystemPrivateKeyPEM(bytes.NewReader(encrypted), "hunter2")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// encrypt with the public key read back, decrypt with both private keys read back
+			data := []byte("Hello World!")
+			ciphertext := new(bytes.Buffer)
+			if err := EncryptCryptosystemContainer(public, ciphertext, bytes.NewReader(data), int64(len(data))); err != nil {
+				t.Fatal(err)
+			}
+			for _, k := range []Cryptosystem{private, decrypted} {
+				plaintext := new(bytes.Buffer)
+				if err := DecryptCryptosystemContainer(k, plaintext, bytes.NewReader(ciphertext.Bytes())); err != nil {
+					t.Fatal(err)
+				}
+				if plaintext.String() != string(data) {
+					t.Errorf("got %q, want %q", plaintext, data)
+				}
+			}
+		})
+	}
+
+	t.Run("not merkle-hellman", func(t *testing.T) {
+		c := new(ChorRivest)
+		if err := c.GenerateKey(NewSeededRand([]byte("chor-rivest")), 8); err != nil {
+			t.Fatal(err)
+		}
+		buf := new(bytes.Buffer)
+		if err := WriteCryptosystemPublicKeyPEM(buf, c); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadPublicKeyPEM(buf); !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("err = %v, want %v", err, ErrInvalidEncoding)
+		}
+	})
+
+	t.Run("mismatched halves", func(t *testing.T) {
+		a, b := new(NaccacheStern), new(NaccacheStern)
+		if err := a.GenerateKey(NewSeededRand([]byte("a")), 8); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.GenerateKey(NewSeededRand([]byte("b")), 8); err != nil {
+			t.Fatal(err)
+		}
+		a.Public = b.Public
+
+		cr := new(ChorRivest)
+		if err := cr.GenerateKey(NewSeededRand([]byte("chor-rivest")), 8); err != nil {
+			t.Fatal(err)
+		}
+		// still a valid public key on its own, but not the one the private key derives
+		cr.Public.C[0], cr.Public.C[1] = cr.Public.C[1], cr.Public.C[0]
+
+		ns := new(NaccacheStern)
+		if err := ns.GenerateKey(NewSeededRand([]byte("naccache-stern")), 8); err != nil {
+			t.Fatal(err)
+		}
+		ns.Public.V[0] = big.NewInt(1)
+
+		for _, c := range []Cryptosystem{a, cr, ns} {
+			data, err := c.MarshalPrivateKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.UnmarshalPrivateKey(data); !errors.Is(err, ErrKeyMismatch) {
+				t.Errorf("%s: err = %v, want %v", c.Scheme(), err, ErrKeyMismatch)
+			}
+		}
+	})
+
+	t.Run("invalid public key", func(t *testing.T) {
+		// decodes, but a 0 value can't come from any PrivateKey
+		data, err := PublicKey(bigInts(1, 0, 3)).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := new(Knapsack).UnmarshalPublicKey(data); err == nil {
+			t.Errorf("err = nil")
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package knapsack
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+)
+
+// maxDlogPrime bounds the prime factors of p^h - 1 that discrete logarithms are computed modulo,
+// baby-step giant-step keeps at least sqrt(maxDlogPrime) field elements in memory.
+var maxDlogPrime = new(big.Int).Lsh(big.NewInt(1), 36)
+
+// maxBabySteps bounds the baby steps kept per prime factor.
+const maxBabySteps = 1 << 18
+
+// primePower is one prime factor of a factorization, with its exponent.
+type primePower struct {
+	prime *big.Int
+	exp   int
+}
+
+// factorize returns the prime factorization of n > 1, smallest prime first.
+// Small factors are found by trial division, the rest with Pollard's rho, so n shouldn't have two large prime factors.
+func factorize(n *big.Int) []primePower {
+	counts := map[string]*primePower{}
+	add := func(prime *big.Int) {
+		if f, ok := counts[prime.String()]; ok {
+			f.exp++
+			return
+		}
+		counts[prime.String()] = &primePower{prime: prime, exp: 1}
+	}
+
+	n = new(big.Int).Set(n)
+	q, r := new(big.Int), new(big.Int)
+	for d := int64(2); d < 1<<12; d++ {
+		for {
+			q.QuoRem(n, big.NewInt(d), r)
+			if r.Sign() != 0 {
+				break
+			}
+			n.Set(q)
+			add(big.NewInt(d))
+		}
+	}
+
+	// split what is left until every part is prime
+	stack := []*big.Int{n}
+	for len(stack) > 0 {
+		m := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		switch {
+		case m.Cmp(big.NewInt(1)) == 0:
+		case m.ProbablyPrime(20):
+			add(m)
+		default:
+			d := pollardRho(m)
+			stack = append(stack, d, new(big.Int).Quo(m, d))
+		}
+	}
+
+	factors := make([]primePower, 0, len(counts))
+	for _, f := range counts {
+		factors = append(factors, *f)
+	}
+	slices.SortFunc(factors, func(a, b primePower) int {
+		return a.prime.Cmp(b.prime)
+	})
+
+	return factors
+}
+
+// pollardRho returns a non-trivial factor of the odd composite n, using Floyd's cycle finding on x^2 + c.
+func pollardRho(n *big.Int) *big.Int {
+	one := big.NewInt(1)
+	for c := int64(1); ; c++ {
+		step := func(x *big.Int) *big.Int {
+			x.Mul(x, x)
+			x.Add(x, big.NewInt(c))
+			return x.Mod(x, n)
+		}
+
+		x, y, d := big.NewInt(2), big.NewInt(2), big.NewInt(1)
+		diff := new(big.Int)
+		for d.Cmp(one) == 0 {
+			step(x)
+			step(step(y))
+			d.GCD(nil, nil, diff.Abs(diff.Sub(x, y)), n)
+		}
+
+		// d == n means the cycle closed without splitting n, try another c
+		if d.Cmp(n) != 0 {
+			return d
+		}
+	}
+}
+
+// dlog computes discrete logarithms to the base g in GF(p^h)*, which must generate the whole group.
+// It uses Pohlig–Hellman, so it is only fast when every prime factor of p^h - 1 is small.
+type dlog struct {
+	gf      field
+	g       poly
+	order   *big.Int // p^h - 1
+	factors []primePower
+	tables  []bsgsTable // one per factor
+}
+
+// bsgsTable holds the baby steps of baby-step giant-step in the subgroup of prime order ℓ,
+// and the generator of the subgroup of order ℓ^e that Pohlig–Hellman works in.
+type bsgsTable struct {
+	base  poly             // g^(order/ℓ^e)
+	m     int64            // the number of baby steps
+	baby  map[string]int64 // gℓ^j -> j for j in [0, m)
+	giant poly             // gℓ^-m
+}
+
+// newDlog precomputes the baby steps for count logarithms: sqrt(ℓ*count) of them, so the
+// table costs about as much as the giant steps of every logarithm together.
+func newDlog(gf field, g poly, factors []primePower, count int) (*dlog, error) {
+	d := &dlog{
+		gf:      gf,
+		g:       g,
+		order:   new(big.Int).Sub(gf.order(), big.NewInt(1)),
+		factors: factors,
+	}
+
+	for _, f := range factors {
+		if f.prime.Cmp(maxDlogPrime) == 1 {
+			return nil, fmt.Errorf("%v^%d - 1 has the prime factor %v, too large for discrete logarithms", gf.p, gf.h(), f.prime)
+		}
+
+		// base generates the subgroup of order ℓ^e, gℓ the one of order ℓ
+		le := new(big.Int).Exp(f.prime, big.NewInt(int64(f.exp)), nil)
+		base := gf.exp(g, new(big.Int).Quo(d.order, le))
+		gl := gf.exp(base, new(big.Int).Quo(le, f.prime))
+
+		m := new(big.Int).Mul(f.prime, big.NewInt(int64(count)))
+		m.Sqrt(m).Add(m, big.NewInt(1))
+		m = bigMin(m, big.NewInt(maxBabySteps), f.prime)
+
+		t := bsgsTable{base: base, m: m.Int64(), baby: make(map[string]int64, m.Int64())}
+		e := gf.one()
+		for j := range t.m {
+			t.baby[e.key()] = j
+			e = gf.mul(e, gl)
+		}
+
+		// gℓ^m is e, its inverse is gℓ^(ℓ-m)
+		t.giant = gf.exp(gl, new(big.Int).Sub(f.prime, m))
+		d.tables = append(d.tables, t)
+	}
+
+	return d, nil
+}
+
+// log returns x between [0, p^h - 1) with g^x = y, for a non-zero y.
+func (d *dlog) log(y poly) (*big.Int, error) {
+	residues := make([]*big.Int, len(d.factors))
+	moduli := make([]*big.Int, len(d.factors))
+
+	for i, f := range d.factors {
+		le := new(big.Int).Exp(f.prime, big.NewInt(int64(f.exp)), nil)
+
+		// move into the subgroup of order ℓ^e, where x mod ℓ^e is the logarithm of yi to the base gi
+		gi := d.tables[i].base
+		yi := d.gf.exp(y, new(big.Int).Quo(d.order, le))
+
+		// solve x mod ℓ^e one base ℓ digit at a time
+		x := big.NewInt(0)
+		lk := big.NewInt(1)
+		for k := range f.exp {
+			// (yi * gi^-x)^(ℓ^(e-k-1)) = gℓ^digit
+			gx := d.gf.exp(gi, new(big.Int).Sub(le, x))
+			e := new(big.Int).Quo(le, new(big.Int).Mul(lk, f.prime))
+			digit, err := d.tables[i].search(d.gf, d.gf.exp(d.gf.mul(yi, gx), e), f.prime)
+			if err != nil {
+				return nil, fmt.Errorf("log mod %v^%d: %w", f.prime, k+1, err)
+			}
+
+			x.Add(x, new(big.Int).Mul(big.NewInt(digit), lk))
+			lk.Mul(lk, f.prime)
+		}
+
+		residues[i], moduli[i] = x, le
+	}
+
+	return crt(residues, moduli), nil
+}
+
+// search returns the j in [0, ℓ) with gℓ^j = y.
+func (t bsgsTable) search(gf field, y poly, l *big.Int) (int64, error) {
+	giants := new(big.Int).Quo(l, big.NewInt(t.m)).Int64()
+	for i := int64(0); i <= giants; i++ {
+		if j, ok := t.baby[y.key()]; ok {
+			return i*t.m + j, nil
+		}
+		y = gf.mul(y, t.giant)
+	}
+
+	return 0, fmt.Errorf("element is not in the subgroup")
+}
+
+// crt returns the x between [0, Πmoduli) with x = residues[i] mod moduli[i], for pairwise coprime moduli.
+func crt(residues, moduli []*big.Int) *big.Int {
+	x := big.NewInt(0)
+	m := big.NewInt(1)
+	for i := range residues {
+		// x + m*k = residues[i] mod moduli[i]
+		k := new(big.Int).Sub(residues[i], x)
+		k.Mul(k, new(big.Int).ModInverse(m, moduli[i]))
+		k.Mod(k, moduli[i])
+
+		x.Add(x, k.Mul(k, m))
+		m.Mul(m, moduli[i])
+	}
+
+	return x
+}
+
+// generates reports whether g generates GF(p^h)*, whose order has the prime factors factors:
+// g^(order/ℓ) != 1 for every prime ℓ.
+func generates(gf field, g poly, factors []primePower) bool {
+	if len(g) == 0 {
+		return false
+	}
+
+	order := new(big.Int).Sub(gf.order(), big.NewInt(1))
+	for _, f := range factors {
+		if gf.exp(g, new(big.Int).Quo(order, f.prime)).key() == gf.one().key() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// bigMin returns the smallest of xs.
+func bigMin(xs ...*big.Int) *big.Int {
+	m := xs[0]
+	for _, x := range xs[1:] {
+		if x.Cmp(m) == -1 {
+			m = x
+		}
+	}
+
+	return m
+}
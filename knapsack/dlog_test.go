@@ -0,0 +1,105 @@
+package knapsack
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFactorize(t *testing.T) {
+	tests := []struct {
+		n    *big.Int
+		want string
+	}{
+		{n: big.NewInt(2), want: "2^1"},
+		{n: big.NewInt(360), want: "2^3 3^2 5^1"},
+		// 2^32 + 1, Euler's factorization of F5
+		{n: big.NewInt(4294967297), want: "641^1 6700417^1"},
+		{n: chorRivestOrder(197, 24), want: "2^5 3^3 5^1 7^2 11^1 13^1 19^1 61^1 73^1 211^1 2053^1 3217^1 3881^1 36013^1 728809^1 750457^1 4147537^1 10316017^1"},
+	}
+
+	for _, tt := range tests {
+		got := ""
+		for i, f := range factorize(tt.n) {
+			if i > 0 {
+				got += " "
+			}
+			got += f.prime.String() + "^" + big.NewInt(int64(f.exp)).String()
+		}
+
+		if got != tt.want {
+			t.Errorf("factorize(%v) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestDlog(t *testing.T) {
+	random := NewSeededRand([]byte("dlog"))
+	f, err := randomIrreducible(random, 13, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gf := field{p: 13, f: f}
+
+	factors := factorize(chorRivestOrder(13, 4))
+	var g poly
+	for !generates(gf, g, factors) {
+		if g, err = randomPoly(random, 13, 4); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := newDlog(gf, g, factors, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, x := range []int64{0, 1, 2, 12, 100, 4095, 28559} {
+		y := gf.exp(g, big.NewInt(x))
+
+		got, err := d.log(y)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Int64() != x {
+			t.Errorf("log(g^%d) = %v", x, got)
+		}
+	}
+}
+
+func TestIrreducible(t *testing.T) {
+	// there are (p^n - p)/n monic irreducible polynomials of prime degree n over GF(p)
+	tests := []struct {
+		p    int64
+		h    int
+		want int
+	}{
+		{p: 5, h: 2, want: 10},
+		{p: 3, h: 3, want: 8},
+		{p: 2, h: 5, want: 6},
+	}
+
+	for _, tt := range tests {
+		total := int64(1)
+		for range tt.h {
+			total *= tt.p
+		}
+
+		count := 0
+		for i := range total {
+			// the coefficients of x^0 to x^(h-1) are the base p digits of i
+			f := make(poly, tt.h+1)
+			for j, r := 0, i; j < tt.h; j, r = j+1, r/tt.p {
+				f[j] = r % tt.p
+			}
+			f[tt.h] = 1
+
+			if irreducible(f, tt.p) {
+				count++
+			}
+		}
+
+		if count != tt.want {
+			t.Errorf("p=%d, h=%d: %d irreducible polynomials, want %d", tt.p, tt.h, count, tt.want)
+		}
+	}
+}
@@ -0,0 +1,684 @@
+package knapsack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// encodingVersion is the version recorded in every serialized header.
+// legacyVersion recorded the block size in bytes rather than bits, and can still be decoded.
+// permVersion is the first version that records a PrivateKey's Perm, older keys decode with a nil Perm.
+// roundsVersion is the first version that records a PrivateKey's Rounds.
+const (
+	encodingVersion = 4
+	roundsVersion   = 4
+	permVersion     = 3
+	legacyVersion   = 1
+)
+
+// binaryMagic starts every binary encoding.
+var binaryMagic = []byte("KNAP")
+
+// ErrInvalidEncoding is returned when serialized data is malformed.
+var ErrInvalidEncoding = errors.New("invalid encoding")
+
+// kind is the type of value being serialized.
+type kind byte
+
+const (
+	kindPublicKey kind = iota + 1
+	kindPrivateKey
+	kindSet
+	kindCiphertext
+	kindContainer
+)
+
+func (k kind) String() string {
+	switch k {
+	case kindPublicKey:
+		return "public"
+	case kindPrivateKey:
+		return "private"
+	case kindSet:
+		return "set"
+	case kindCiphertext:
+		return "ciphertext"
+	case kindContainer:
+		return "container"
+	default:
+		return fmt.Sprintf("kind(%d)", byte(k))
+	}
+}
+
+// encoded is the common form of every serialized type.
+//
+// Binary: "KNAP" | version byte | kind byte | uvarint bits | [V block | U block] | uvarint count | blocks...
+// | [uvarint count | uvarint indexes... | uvarint count | (V block | U block)...]
+// where a block is a uvarint length followed by big-endian bytes.
+//
+// Text: kind:version:bits[:V:U]:values[:perm:rounds], where values and perm are comma separated decimals,
+// and rounds are comma separated V/U pairs.
+//
+// JSON: {"version":4,"kind":"...","bits":8[,"v":13,"u":672],"values":[...][,"perm":[...],"rounds":[{"v":..,"u":..}]]}
+//
+// bits is the number of bits per block, V, U, perm and rounds are only present for a PrivateKey.
+// An empty perm is a nil Perm. A Ciphertext doesn't know which block size encrypted it, so its bits is 0.
+type encoded struct {
+	kind   kind
+	bits   int
+	v, u   *big.Int
+	values []*big.Int
+	perm   []int
+	rounds []Round
+}
+
+// hasKey reports if V and U are part of the encoding.
+func (e *encoded) hasKey() bool {
+	return e.kind == kindPrivateKey
+}
+
+// validate checks the values are usable as e's kind.
+func (e *encoded) validate() error {
+	// a Ciphertext has no block size, everything else encrypts at least 1 bit per block
+	if e.bits < 0 || (e.kind != kindCiphertext && e.bits < 1) {
+		return fmt.Errorf("%w: invalid bits %d", ErrInvalidEncoding, e.bits)
+	}
+
+	// everything but a Ciphertext has a value per plaintext bit
+	if e.kind != kindCiphertext && len(e.values) != e.bits {
+		return fmt.Errorf("%w: %d values don't match %d bits", ErrInvalidEncoding, len(e.values), e.bits)
+	}
+
+	if e.hasKey() && (e.v == nil || e.u == nil || e.v.Sign() < 0 || e.u.Sign() < 0) {
+		return fmt.Errorf("%w: missing or negative v, u", ErrInvalidEncoding)
+	}
+
+	for _, v := range e.values {
+		if v == nil || v.Sign() < 0 {
+			return fmt.Errorf("%w: missing or negative value", ErrInvalidEncoding)
+		}
+	}
+
+	if err := validatePerm(e.perm, len(e.values)); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+
+	for _, r := range e.rounds {
+		if r.V == nil || r.U == nil || r.V.Sign() < 0 || r.U.Sign() < 0 {
+			return fmt.Errorf("%w: missing or negative round v, u", ErrInvalidEncoding)
+		}
+	}
+
+	return nil
+}
+
+// appendBlock appends b as a uvarint length followed by b's big-endian bytes.
+func appendBlock(buf []byte, b *big.Int) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b.Bytes())))
+	return append(buf, b.Bytes()...)
+}
+
+func (e *encoded) marshalBinary() ([]byte, error) {
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+
+	buf := append([]byte{}, binaryMagic...)
+	buf = append(buf, encodingVersion, byte(e.kind))
+	buf = binary.AppendUvarint(buf, uint64(e.bits))
+
+	if e.hasKey() {
+		buf = appendBlock(buf, e.v)
+		buf = appendBlock(buf, e.u)
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(e.values)))
+	for _, v := range e.values {
+		buf = appendBlock(buf, v)
+	}
+
+	if e.hasKey() {
+		buf = binary.AppendUvarint(buf, uint64(len(e.perm)))
+		for _, p := range e.perm {
+			buf = binary.AppendUvarint(buf, uint64(p))
+		}
+
+		buf = binary.AppendUvarint(buf, uint64(len(e.rounds)))
+		for _, r := range e.rounds {
+			buf = appendBlock(buf, r.V)
+			buf = appendBlock(buf, r.U)
+		}
+	}
+
+	return buf, nil
+}
+
+// binaryDecoder reads from a binary encoding, keeping the first error.
+type binaryDecoder struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (d *binaryDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	u, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		d.err = fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+
+	return u
+}
+
+func (d *binaryDecoder) block() *big.Int {
+	l := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+
+	if l > uint64(d.r.Len()) {
+		d.err = fmt.Errorf("%w: block length %d is larger than the remaining %d bytes", ErrInvalidEncoding, l, d.r.Len())
+		return nil
+	}
+
+	buf := make([]byte, l)
+	_, _ = d.r.Read(buf)
+
+	return new(big.Int).SetBytes(buf)
+}
+
+// perm reads a uvarint count followed by that many uvarint indexes below n.
+func (d *binaryDecoder) perm(n int) []int {
+	count := d.uvarint()
+	if d.err != nil || count == 0 {
+		return nil
+	}
+
+	if count > uint64(n) {
+		d.err = fmt.Errorf("%w: %d permutation indexes for %d values", ErrInvalidEncoding, count, n)
+		return nil
+	}
+
+	perm := make([]int, count)
+	for i := range perm {
+		p := d.uvarint()
+		if p >= uint64(n) {
+			d.err = fmt.Errorf("%w: permutation index %d is out of range", ErrInvalidEncoding, p)
+			return nil
+		}
+		perm[i] = int(p)
+	}
+
+	return perm
+}
+
+// rounds reads a uvarint count followed by that many V and U blocks.
+func (d *binaryDecoder) rounds() []Round {
+	count := d.uvarint()
+	if d.err != nil || count == 0 {
+		return nil
+	}
+
+	// every block is at least 1 byte long
+	if count > uint64(d.r.Len()) {
+		d.err = fmt.Errorf("%w: %d rounds in %d bytes", ErrInvalidEncoding, count, d.r.Len())
+		return nil
+	}
+
+	var rounds []Round
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		rounds = append(rounds, Round{V: d.block(), U: d.block()})
+	}
+
+	return rounds
+}
+
+func unmarshalBinary(data []byte, want kind) (*encoded, error) {
+	if len(data) < len(binaryMagic)+2 || !bytes.Equal(data[:len(binaryMagic)], binaryMagic) {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidEncoding)
+	}
+
+	version := int(data[len(binaryMagic)])
+	e := &encoded{kind: kind(data[len(binaryMagic)+1])}
+	if err := checkHeader(version, e.kind, want); err != nil {
+		return nil, err
+	}
+
+	d := &binaryDecoder{r: bytes.NewReader(data[len(binaryMagic)+2:])}
+
+	bits := d.uvarint()
+	if e.hasKey() {
+		e.v = d.block()
+		e.u = d.block()
+	}
+
+	count := d.uvarint()
+	if d.err == nil && count > uint64(d.r.Len()) {
+		// every block is at least 1 byte long
+		return nil, fmt.Errorf("%w: %d values in %d bytes", ErrInvalidEncoding, count, d.r.Len())
+	}
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		e.values = append(e.values, d.block())
+	}
+
+	if e.hasKey() && version >= permVersion {
+		e.perm = d.perm(len(e.values))
+	}
+	if e.hasKey() && version >= roundsVersion {
+		e.rounds = d.rounds()
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	if d.r.Len() != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidEncoding, d.r.Len())
+	}
+	if bits > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: %d bits is too large", ErrInvalidEncoding, bits)
+	}
+	e.bits = headerBits(version, int(bits))
+
+	return e, e.validate()
+}
+
+// checkHeader checks the version and kind of a decoded header.
+func checkHeader(version int, got, want kind) error {
+	if version < legacyVersion || version > encodingVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidEncoding, version)
+	}
+
+	if got != want {
+		return fmt.Errorf("%w: got %v, want %v", ErrInvalidEncoding, got, want)
+	}
+
+	return nil
+}
+
+// headerBits converts the size recorded in a header of version to bits.
+func headerBits(version, size int) int {
+	if version == legacyVersion {
+		// the block size in bytes
+		return 8 * size
+	}
+
+	return size
+}
+
+func (e *encoded) marshalText() ([]byte, error) {
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+
+	s := strings.Builder{}
+	s.WriteString(fmt.Sprintf("%v:%d:%d:", e.kind, encodingVersion, e.bits))
+
+	if e.hasKey() {
+		s.WriteString(fmt.Sprintf("%d:%d:", e.v, e.u))
+	}
+
+	for i, v := range e.values {
+		s.WriteString(v.String())
+		if i < len(e.values)-1 {
+			s.WriteString(",")
+		}
+	}
+
+	if e.hasKey() {
+		s.WriteString(":")
+		for i, p := range e.perm {
+			s.WriteString(strconv.Itoa(p))
+			if i < len(e.perm)-1 {
+				s.WriteString(",")
+			}
+		}
+
+		s.WriteString(":")
+		for i, r := range e.rounds {
+			s.WriteString(fmt.Sprintf("%d/%d", r.V, r.U))
+			if i < len(e.rounds)-1 {
+				s.WriteString(",")
+			}
+		}
+	}
+
+	return []byte(s.String()), nil
+}
+
+func unmarshalText(text []byte, want kind) (*encoded, error) {
+	fields := strings.Split(string(text), ":")
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidEncoding)
+	}
+
+	if fields[0] != want.String() {
+		return nil, fmt.Errorf("%w: got %s, want %v", ErrInvalidEncoding, fields[0], want)
+	}
+
+	version, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: version: %v", ErrInvalidEncoding, err)
+	}
+	if err := checkHeader(version, want, want); err != nil {
+		return nil, err
+	}
+
+	n := 4
+	if want == kindPrivateKey {
+		n = 6
+		if version >= permVersion {
+			n = 7
+		}
+		if version >= roundsVersion {
+			n = 8
+		}
+	}
+	if len(fields) != n {
+		return nil, fmt.Errorf("%w: got %d fields, want %d", ErrInvalidEncoding, len(fields), n)
+	}
+
+	e := &encoded{kind: want}
+
+	bits, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: bits: %v", ErrInvalidEncoding, err)
+	}
+	e.bits = headerBits(version, bits)
+
+	if e.hasKey() {
+		e.v, err = parseBigInt(fields[3])
+		if err != nil {
+			return nil, err
+		}
+		e.u, err = parseBigInt(fields[4])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	values := fields[3]
+	if e.hasKey() {
+		values = fields[5]
+	}
+	if values != "" {
+		for _, str := range strings.Split(values, ",") {
+			v, err := parseBigInt(str)
+			if err != nil {
+				return nil, err
+			}
+			e.values = append(e.values, v)
+		}
+	}
+
+	if e.hasKey() && version >= permVersion && fields[6] != "" {
+		for _, str := range strings.Split(fields[6], ",") {
+			p, err := strconv.Atoi(strings.TrimSpace(str))
+			if err != nil {
+				return nil, fmt.Errorf("%w: perm: %v", ErrInvalidEncoding, err)
+			}
+			e.perm = append(e.perm, p)
+		}
+	}
+
+	if e.hasKey() && version >= roundsVersion && fields[7] != "" {
+		for _, str := range strings.Split(fields[7], ",") {
+			vStr, uStr, found := strings.Cut(str, "/")
+			if !found {
+				return nil, fmt.Errorf("%w: round %q is not V/U", ErrInvalidEncoding, str)
+			}
+
+			v, err := parseBigInt(vStr)
+			if err != nil {
+				return nil, err
+			}
+			u, err := parseBigInt(uStr)
+			if err != nil {
+				return nil, err
+			}
+			e.rounds = append(e.rounds, Round{V: v, U: u})
+		}
+	}
+
+	return e, e.validate()
+}
+
+func parseBigInt(s string) (*big.Int, error) {
+	i, success := new(big.Int).SetString(strings.TrimSpace(s), 10)
+	if !success {
+		return nil, fmt.Errorf("%w: %q is not an integer", ErrInvalidEncoding, s)
+	}
+
+	return i, nil
+}
+
+// jsonEncoded is the JSON form of encoded.
+// BlockSize is only read from the legacyVersion.
+type jsonEncoded struct {
+	Version   int        `json:"version"`
+	Kind      string     `json:"kind"`
+	Bits      int        `json:"bits"`
+	BlockSize int        `json:"blockSize,omitempty"`
+	V         *big.Int   `json:"v,omitempty"`
+	U         *big.Int   `json:"u,omitempty"`
+	Values    []*big.Int `json:"values"`
+	Perm      []int      `json:"perm,omitempty"`
+	Rounds    []Round    `json:"rounds,omitempty"`
+}
+
+func (e *encoded) marshalJSON() ([]byte, error) {
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+
+	j := jsonEncoded{
+		Version: encodingVersion,
+		Kind:    e.kind.String(),
+		Bits:    e.bits,
+		V:       e.v,
+		U:       e.u,
+		Values:  e.values,
+		Perm:    e.perm,
+		Rounds:  e.rounds,
+	}
+
+	if j.Values == nil {
+		j.Values = []*big.Int{}
+	}
+
+	return json.Marshal(j)
+}
+
+func unmarshalJSON(data []byte, want kind) (*encoded, error) {
+	var j jsonEncoded
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+
+	if j.Kind != want.String() {
+		return nil, fmt.Errorf("%w: got %s, want %v", ErrInvalidEncoding, j.Kind, want)
+	}
+	if err := checkHeader(j.Version, want, want); err != nil {
+		return nil, err
+	}
+
+	e := &encoded{
+		kind:   want,
+		bits:   j.Bits,
+		v:      j.V,
+		u:      j.U,
+		values: j.Values,
+	}
+	if e.hasKey() && len(j.Perm) != 0 {
+		e.perm = j.Perm
+	}
+	if e.hasKey() && len(j.Rounds) != 0 {
+		e.rounds = j.Rounds
+	}
+	if j.Version == legacyVersion {
+		e.bits = headerBits(j.Version, j.BlockSize)
+	}
+
+	return e, e.validate()
+}
+
+func (p PublicKey) encoded() *encoded {
+	return &encoded{kind: kindPublicKey, bits: p.Bits(), values: p}
+}
+
+func (p *PublicKey) decoded(e *encoded, err error) error {
+	if err != nil {
+		return err
+	}
+
+	*p = e.values
+	return nil
+}
+
+func (p PublicKey) MarshalBinary() ([]byte, error) {
+	return p.encoded().marshalBinary()
+}
+
+func (p *PublicKey) UnmarshalBinary(data []byte) error {
+	return p.decoded(unmarshalBinary(data, kindPublicKey))
+}
+
+func (p PublicKey) MarshalText() ([]byte, error) {
+	return p.encoded().marshalText()
+}
+
+func (p *PublicKey) UnmarshalText(text []byte) error {
+	return p.decoded(unmarshalText(text, kindPublicKey))
+}
+
+func (p PublicKey) MarshalJSON() ([]byte, error) {
+	return p.encoded().marshalJSON()
+}
+
+func (p *PublicKey) UnmarshalJSON(data []byte) error {
+	return p.decoded(unmarshalJSON(data, kindPublicKey))
+}
+
+func (p *PrivateKey) encoded() *encoded {
+	return &encoded{kind: kindPrivateKey, bits: p.Bits(), v: p.V, u: p.U, values: p.Set, perm: p.Perm, rounds: p.Rounds}
+}
+
+func (p *PrivateKey) decoded(e *encoded, err error) error {
+	if err != nil {
+		return err
+	}
+
+	*p = PrivateKey{
+		Set:    e.values,
+		V:      e.v,
+		U:      e.u,
+		Perm:   e.perm,
+		Rounds: e.rounds,
+	}
+	return nil
+}
+
+func (p *PrivateKey) MarshalBinary() ([]byte, error) {
+	return p.encoded().marshalBinary()
+}
+
+func (p *PrivateKey) UnmarshalBinary(data []byte) error {
+	return p.decoded(unmarshalBinary(data, kindPrivateKey))
+}
+
+func (p *PrivateKey) MarshalText() ([]byte, error) {
+	return p.encoded().marshalText()
+}
+
+func (p *PrivateKey) UnmarshalText(text []byte) error {
+	return p.decoded(unmarshalText(text, kindPrivateKey))
+}
+
+func (p *PrivateKey) MarshalJSON() ([]byte, error) {
+	return p.encoded().marshalJSON()
+}
+
+func (p *PrivateKey) UnmarshalJSON(data []byte) error {
+	return p.decoded(unmarshalJSON(data, kindPrivateKey))
+}
+
+func (s Set) encoded() *encoded {
+	return &encoded{kind: kindSet, bits: len(s), values: s}
+}
+
+func (s *Set) decoded(e *encoded, err error) error {
+	if err != nil {
+		return err
+	}
+
+	*s = e.values
+	return nil
+}
+
+func (s Set) MarshalBinary() ([]byte, error) {
+	return s.encoded().marshalBinary()
+}
+
+func (s *Set) UnmarshalBinary(data []byte) error {
+	return s.decoded(unmarshalBinary(data, kindSet))
+}
+
+func (s Set) MarshalText() ([]byte, error) {
+	return s.encoded().marshalText()
+}
+
+func (s *Set) UnmarshalText(text []byte) error {
+	return s.decoded(unmarshalText(text, kindSet))
+}
+
+func (s Set) MarshalJSON() ([]byte, error) {
+	return s.encoded().marshalJSON()
+}
+
+func (s *Set) UnmarshalJSON(data []byte) error {
+	return s.decoded(unmarshalJSON(data, kindSet))
+}
+
+func (c Ciphertext) encoded() *encoded {
+	return &encoded{kind: kindCiphertext, values: c}
+}
+
+func (c *Ciphertext) decoded(e *encoded, err error) error {
+	if err != nil {
+		return err
+	}
+
+	*c = e.values
+	return nil
+}
+
+func (c Ciphertext) MarshalBinary() ([]byte, error) {
+	return c.encoded().marshalBinary()
+}
+
+func (c *Ciphertext) UnmarshalBinary(data []byte) error {
+	return c.decoded(unmarshalBinary(data, kindCiphertext))
+}
+
+func (c Ciphertext) MarshalText() ([]byte, error) {
+	return c.encoded().marshalText()
+}
+
+func (c *Ciphertext) UnmarshalText(text []byte) error {
+	return c.decoded(unmarshalText(text, kindCiphertext))
+}
+
+func (c Ciphertext) MarshalJSON() ([]byte, error) {
+	return c.encoded().marshalJSON()
+}
+
+func (c *Ciphertext) UnmarshalJSON(data []byte) error {
+	return c.decoded(unmarshalJSON(data, kindCiphertext))
+}
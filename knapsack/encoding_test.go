@@ -0,0 +1,237 @@
+package knapsack
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	mathRand "math/rand/v2"
+	"testing"
+)
+
+// formats are the encodings every serializable type supports.
+var formats = []struct {
+	name      string
+	marshal   func(v any) ([]byte, error)
+	unmarshal func(data []byte, v any) error
+}{
+	{
+		name:      "binary",
+		marshal:   func(v any) ([]byte, error) { return v.(encoding.BinaryMarshaler).MarshalBinary() },
+		unmarshal: func(data []byte, v any) error { return v.(encoding.BinaryUnmarshaler).UnmarshalBinary(data) },
+	},
+	{
+		name:      "text",
+		marshal:   func(v any) ([]byte, error) { return v.(encoding.TextMarshaler).MarshalText() },
+		unmarshal: func(data []byte, v any) error { return v.(encoding.TextUnmarshaler).UnmarshalText(data) },
+	},
+	{
+		name:      "json",
+		marshal:   json.Marshal,
+		unmarshal: json.Unmarshal,
+	},
+}
+
+func TestEncoding(t *testing.T) {
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			for range 20 {
+				r := (mathRand.Int() % 8) + 1
+
+				k, err := NewKnapsack(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				cipher := k.Encrypt(k.NewPlaintext([]byte("Hello World!")))
+
+				tests := []struct {
+					in  any
+					out any
+				}{
+					{in: k.Public, out: new(PublicKey)},
+					{in: k.Private, out: new(PrivateKey)},
+					{in: k.Private.Set, out: new(Set)},
+					{in: cipher, out: new(Ciphertext)},
+				}
+
+				for _, tt := range tests {
+					data, err := f.marshal(tt.in)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if err := f.unmarshal(data, tt.out); err != nil {
+						t.Fatalf("unmarshal %T: %v\n%s", tt.in, err, data)
+					}
+
+					// compare as text, big.Int's internals are not always equal
+					want, _ := f.marshal(tt.in)
+					got, _ := f.marshal(tt.out)
+					if string(got) != string(want) {
+						t.Errorf("got %s, want %s", got, want)
+					}
+				}
+
+				// the reloaded keys must still work
+				private := new(PrivateKey)
+				data, _ := f.marshal(k.Private)
+				if err := f.unmarshal(data, private); err != nil {
+					t.Fatal(err)
+				}
+
+				plain, err := private.Decrypt(cipher)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := FromPlaintextBits(private.Bits(), plain)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != "Hello World!" {
+					t.Errorf("got %q, want %q", got, "Hello World!")
+				}
+			}
+		})
+	}
+}
+
+func TestEncodingText(t *testing.T) {
+	want := &PrivateKey{
+		Set: bigInts(3, 5, 9, 18, 38, 75, 155, 310),
+		V:   big.NewInt(13),
+		U:   big.NewInt(672),
+	}
+
+	tests := []struct {
+		name string
+		text string
+	}{
+		// version 1 recorded the block size in bytes
+		{name: "v1", text: "private:1:1:13:672:3,5,9,18,38,75,155,310"},
+		{name: "v2", text: "private:2:8:13:672:3,5,9,18,38,75,155,310"},
+		// an empty perm keeps the Set's order
+		{name: "v3", text: "private:3:8:13:672:3,5,9,18,38,75,155,310:"},
+		// as do empty rounds
+		{name: "v4", text: "private:4:8:13:672:3,5,9,18,38,75,155,310::"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			private := &PrivateKey{}
+			if err := private.UnmarshalText([]byte(tt.text)); err != nil {
+				t.Fatal(err)
+			}
+
+			if fmt.Sprint(*private) != fmt.Sprint(*want) {
+				t.Errorf("got %v, want %v", *private, *want)
+			}
+		})
+	}
+
+	text, err := want.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(text) != tests[3].text {
+		t.Errorf("got %q, want %q", text, tests[3].text)
+	}
+
+	t.Run("perm", func(t *testing.T) {
+		text := "private:4:8:13:672:3,5,9,18,38,75,155,310:7,0,6,1,5,2,4,3:"
+
+		private := &PrivateKey{}
+		if err := private.UnmarshalText([]byte(text)); err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(private.Perm) != "[7 0 6 1 5 2 4 3]" {
+			t.Errorf("got %v, want [7 0 6 1 5 2 4 3]", private.Perm)
+		}
+
+		got, err := private.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != text {
+			t.Errorf("got %q, want %q", got, text)
+		}
+	})
+
+	t.Run("rounds", func(t *testing.T) {
+		text := "private:4:8:13:672:3,5,9,18,38,75,155,310::1001/2953,5/9000"
+
+		private := &PrivateKey{}
+		if err := private.UnmarshalText([]byte(text)); err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(private.Rounds) != "[{1001 2953} {5 9000}]" {
+			t.Errorf("got %v, want [{1001 2953} {5 9000}]", private.Rounds)
+		}
+
+		got, err := private.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != text {
+			t.Errorf("got %q, want %q", got, text)
+		}
+	})
+}
+
+func TestEncodingInvalid(t *testing.T) {
+	public, err := PublicKey(bigInts(1, 2, 3, 4, 5, 6, 7, 8)).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		into encoding.BinaryUnmarshaler
+	}{
+		{name: "empty", data: []byte{}, into: new(PublicKey)},
+		{name: "wrong kind", data: public, into: new(Set)},
+		{name: "wrong version", data: append(append([]byte("KNAP"), encodingVersion+1), public[5:]...), into: new(PublicKey)},
+		{name: "truncated", data: public[:len(public)-1], into: new(PublicKey)},
+		{name: "trailing", data: append(public, 0), into: new(PublicKey)},
+		{name: "zero bits", data: append([]byte("KNAP"), encodingVersion, byte(kindPublicKey), 0, 0), into: new(PublicKey)},
+		{name: "zero bit set", data: append([]byte("KNAP"), encodingVersion, byte(kindSet), 0, 0), into: new(Set)},
+		{name: "zero bit private key", data: append([]byte("KNAP"), encodingVersion, byte(kindPrivateKey), 0, 1, 13, 2, 2, 160, 0, 0, 0), into: new(PrivateKey)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.into.UnmarshalBinary(tt.data); !errors.Is(err, ErrInvalidEncoding) {
+				t.Errorf("err = %v, want %v", err, ErrInvalidEncoding)
+			}
+		})
+	}
+
+	texts := []struct {
+		name string
+		text string
+		into encoding.TextUnmarshaler
+	}{
+		{name: "block size mismatch", text: "public:1:2:1,2,3,4,5,6,7,8", into: new(PublicKey)},
+		{name: "bits mismatch", text: "public:2:9:1,2,3,4,5,6,7,8", into: new(PublicKey)},
+		{name: "no values", text: "public:4:0:", into: new(PublicKey)},
+		{name: "not an integer", text: "set:1:1:1,2,3,4,5,6,7,x", into: new(Set)},
+		{name: "negative", text: "ciphertext:1:0:-1", into: new(Ciphertext)},
+		{name: "missing key", text: "private:1:1:1,2,3,4,5,6,7,8", into: new(PrivateKey)},
+		{name: "missing perm", text: "private:3:8:13:672:3,5,9,18,38,75,155,310", into: new(PrivateKey)},
+		{name: "repeated perm", text: "private:3:8:13:672:3,5,9,18,38,75,155,310:0,0,1,2,3,4,5,6", into: new(PrivateKey)},
+		{name: "short perm", text: "private:3:8:13:672:3,5,9,18,38,75,155,310:1,0", into: new(PrivateKey)},
+		{name: "invalid round", text: "private:4:8:13:672:3,5,9,18,38,75,155,310::1001", into: new(PrivateKey)},
+		{name: "perm out of range", text: "private:3:8:13:672:3,5,9,18,38,75,155,310:0,1,2,3,4,5,6,8", into: new(PrivateKey)},
+	}
+
+	for _, tt := range texts {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.into.UnmarshalText([]byte(tt.text)); !errors.Is(err, ErrInvalidEncoding) {
+				t.Errorf("err = %v, want %v", err, ErrInvalidEncoding)
+			}
+		})
+	}
+}
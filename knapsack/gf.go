@@ -0,0 +1,233 @@
+package knapsack
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+	"slices"
+)
+
+// poly is a polynomial over GF(p), its coefficients from the constant term up, each between [0, p).
+// It is trimmed so the last coefficient isn't 0, the zero polynomial is empty.
+type poly []int64
+
+func (a poly) degree() int {
+	return len(a) - 1
+}
+
+func (a poly) trim() poly {
+	for len(a) > 0 && a[len(a)-1] == 0 {
+		a = a[:len(a)-1]
+	}
+
+	return a
+}
+
+// key returns a comparable form of a, for map keys.
+func (a poly) key() string {
+	// coefficients are below maxChorRivestP < 2^24, 3 bytes each
+	b := make([]byte, 0, 3*len(a))
+	for _, c := range a {
+		b = append(b, byte(c>>16), byte(c>>8), byte(c))
+	}
+
+	return string(b)
+}
+
+// eval returns a(x) mod p.
+func (a poly) eval(x, p int64) int64 {
+	var y int64
+	for i := len(a) - 1; i >= 0; i-- {
+		y = (y*x + a[i]) % p
+	}
+
+	return y
+}
+
+func polySub(a, b poly, p int64) poly {
+	c := make(poly, max(len(a), len(b)))
+	copy(c, a)
+	for i, bi := range b {
+		c[i] = ((c[i]-bi)%p + p) % p
+	}
+
+	return c.trim()
+}
+
+// polyMod returns a mod b, for a non-zero b.
+func polyMod(a, b poly, p int64) poly {
+	r := slices.Clone(a).trim()
+	lead := new(big.Int).ModInverse(big.NewInt(b[b.degree()]), big.NewInt(p)).Int64()
+
+	for r.degree() >= b.degree() {
+		// subtract q*x^shift*b to clear r's leading coefficient
+		q := r[r.degree()] * lead % p
+		shift := r.degree() - b.degree()
+		for i, bi := range b {
+			r[shift+i] = ((r[shift+i]-q*bi)%p + p) % p
+		}
+		r = r.trim()
+	}
+
+	return r
+}
+
+func polyGCD(a, b poly, p int64) poly {
+	for len(b) > 0 {
+		a, b = b, polyMod(a, b, p)
+	}
+
+	return a
+}
+
+// field is GF(p^h) as the polynomials over GF(p) modulo the monic irreducible f of degree h.
+type field struct {
+	p int64
+	f poly
+}
+
+func (gf field) h() int {
+	return gf.f.degree()
+}
+
+// order returns p^h.
+func (gf field) order() *big.Int {
+	return new(big.Int).Exp(big.NewInt(gf.p), big.NewInt(int64(gf.h())), nil)
+}
+
+func (gf field) one() poly {
+	return poly{1}
+}
+
+// x returns the root of f, t in Chor–Rivest's notation.
+func (gf field) x() poly {
+	return poly{0, 1}
+}
+
+func (gf field) mul(a, b poly) poly {
+	if len(a) == 0 || len(b) == 0 {
+		return nil
+	}
+
+	// coefficients are below 2^20 and h < p, so every sum below stays within ±2h*p^2 < 2^61
+	// and only needs reducing once at the end
+	c := make(poly, len(a)+len(b)-1)
+	for i, ai := range a {
+		for j, bj := range b {
+			c[i+j] += ai * bj
+		}
+	}
+
+	// f is monic, so x^h = -(f - x^h) clears each leading coefficient
+	h := gf.h()
+	for k := len(c) - 1; k >= h; k-- {
+		q := c[k] % gf.p
+		for i, fi := range gf.f[:h] {
+			c[k-h+i] -= q * fi
+		}
+	}
+
+	c = c[:min(len(c), h)]
+	for i := range c {
+		c[i] %= gf.p
+		if c[i] < 0 {
+			c[i] += gf.p
+		}
+	}
+
+	return c.trim()
+}
+
+// exp returns a^e for e >= 0.
+func (gf field) exp(a poly, e *big.Int) poly {
+	r := gf.one()
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		r = gf.mul(r, r)
+		if e.Bit(i) == 1 {
+			r = gf.mul(r, a)
+		}
+	}
+
+	return r
+}
+
+// frobenius returns a^(p^k).
+func (gf field) frobenius(a poly, k int) poly {
+	p := big.NewInt(gf.p)
+	for range k {
+		a = gf.exp(a, p)
+	}
+
+	return a
+}
+
+// irreducible reports whether f is irreducible over GF(p) with Rabin's test:
+// x^(p^h) = x mod f, and GCD(x^(p^(h/r)) - x, f) = 1 for every prime r dividing h.
+func irreducible(f poly, p int64) bool {
+	h := f.degree()
+	if h < 1 {
+		return false
+	}
+
+	gf := field{p: p, f: f}
+	x := polyMod(gf.x(), f, p)
+
+	for _, r := range smallPrimeFactors(h) {
+		d := polySub(gf.frobenius(x, h/r), x, p)
+		if polyGCD(f, d, p).degree() != 0 {
+			return false
+		}
+	}
+
+	return polySub(gf.frobenius(x, h), x, p).degree() < 0
+}
+
+// smallPrimeFactors returns the distinct prime factors of n > 0.
+func smallPrimeFactors(n int) []int {
+	var factors []int
+	for r := 2; r*r <= n; r++ {
+		if n%r == 0 {
+			factors = append(factors, r)
+			for n%r == 0 {
+				n /= r
+			}
+		}
+	}
+	if n > 1 {
+		factors = append(factors, n)
+	}
+
+	return factors
+}
+
+// randomPoly returns a polynomial of degree < h with random coefficients from GF(p).
+func randomPoly(random io.Reader, p int64, h int) (poly, error) {
+	a := make(poly, h)
+	for i := range a {
+		c, err := rand.Int(random, big.NewInt(p))
+		if err != nil {
+			return nil, err
+		}
+		a[i] = c.Int64()
+	}
+
+	return a.trim(), nil
+}
+
+// randomIrreducible returns a random monic irreducible polynomial of degree h over GF(p).
+// About 1 in h monic polynomials is irreducible.
+func randomIrreducible(random io.Reader, p int64, h int) (poly, error) {
+	for {
+		f, err := randomPoly(random, p, h)
+		if err != nil {
+			return nil, err
+		}
+
+		f = append(f, make(poly, h+1-len(f))...)
+		f[h] = 1
+
+		if irreducible(f, p) {
+			return f, nil
+		}
+	}
+}
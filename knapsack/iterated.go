@@ -0,0 +1,118 @@
+package knapsack
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Round is one extra modular multiplication of the iterated Merkle–Hellman scheme.
+// Each Round turns the knapsack w into V*w[i] % U, hiding the structure Shamir's attack looks for
+// behind another trapdoor. U must be larger than the sum of w, so the Round can be undone exactly.
+type Round struct {
+	V *big.Int `json:"v"`
+	U *big.Int `json:"u"`
+}
+
+// intermediate returns the knapsack after the PrivateKey's V and U and the first k Rounds, in the Set's order.
+func (p *PrivateKey) intermediate(s Set, k int) []*big.Int {
+	w := make([]*big.Int, len(s))
+	for i, si := range s {
+		wi := new(big.Int).Mul(p.V, si)
+		w[i] = wi.Mod(wi, p.U)
+	}
+
+	for _, r := range p.Rounds[:k] {
+		for i := range w {
+			w[i] = new(big.Int).Mul(r.V, w[i])
+			w[i].Mod(w[i], r.U)
+		}
+	}
+
+	return w
+}
+
+// modulus returns the modulus of the last multiplication building the PublicKey, which bounds every PublicKey value:
+// the last Round's U, or U without Rounds.
+func (p *PrivateKey) modulus() *big.Int {
+	if len(p.Rounds) == 0 {
+		return p.U
+	}
+
+	return p.Rounds[len(p.Rounds)-1].U
+}
+
+// unwind undoes every Round of cipher, last first, leaving a Ciphertext of the knapsack built from just V and U.
+func (p *PrivateKey) unwind(cipher Ciphertext) (Ciphertext, error) {
+	if len(p.Rounds) == 0 {
+		return cipher, nil
+	}
+
+	inverses := make([]*big.Int, len(p.Rounds))
+	for k, r := range p.Rounds {
+		inverse, err := modInverse(r.V, r.U)
+		if err != nil {
+			return nil, fmt.Errorf("round %d: %w", k, err)
+		}
+		inverses[k] = inverse
+	}
+
+	unwound := make(Ciphertext, len(cipher))
+	for b, block := range cipher {
+		t := new(big.Int).Set(block)
+		for k := len(p.Rounds) - 1; k >= 0; k-- {
+			t.Mul(t, inverses[k])
+			t.Mod(t, p.Rounds[k].U)
+		}
+		unwound[b] = t
+	}
+
+	return unwound, nil
+}
+
+// validateRounds checks that every Round's U is larger than the sum of the knapsack it multiplies,
+// and that 0 < V < U with GCD(V, U) = 1.
+func (p *PrivateKey) validateRounds() error {
+	for k, r := range p.Rounds {
+		sum := big.NewInt(0)
+		for _, wi := range p.intermediate(p.Set, k) {
+			sum.Add(sum, wi)
+		}
+
+		if r.U == nil || r.U.Cmp(sum) != 1 {
+			return fmt.Errorf("%w: round %d: U = %v, sum = %v", ErrModulusTooSmall, k, r.U, sum)
+		}
+
+		if r.V == nil || r.V.Sign() <= 0 || r.V.Cmp(r.U) != -1 {
+			return fmt.Errorf("%w: round %d: V = %v, U = %v", ErrMultiplierRange, k, r.V, r.U)
+		}
+
+		if !validGCD(r.V, r.U) {
+			return fmt.Errorf("%w: round %d: GCD(%v, %v) != 1", ErrNotCoprime, k, r.V, r.U)
+		}
+	}
+
+	return nil
+}
+
+// randomRounds appends rounds random Rounds to p, each with sum(w) < U <= 2*sum(w).
+func (p *PrivateKey) randomRounds(random io.Reader, rounds int) error {
+	for range rounds {
+		sum := big.NewInt(0)
+		for _, wi := range p.intermediate(p.Set, len(p.Rounds)) {
+			sum.Add(sum, wi)
+		}
+
+		pMax := new(big.Int).Lsh(sum, 1)
+		pMax.Add(pMax, big.NewInt(1))
+
+		v, u, err := randomMultiplier(random, sum, pMax)
+		if err != nil {
+			return err
+		}
+
+		p.Rounds = append(p.Rounds, Round{V: v, U: u})
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package knapsack
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/big"
+	mathRand "math/rand/v2"
+	"testing"
+)
+
+func TestIterated(t *testing.T) {
+	t.Run("random", func(t *testing.T) {
+		for range 50 {
+			n := mathRand.IntN(100) + 1
+			rounds := mathRand.IntN(4)
+
+			k, err := NewKnapsackBits(n, Options{Rounds: rounds})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(k.Private.Rounds) != rounds {
+				t.Fatalf("got %d rounds, want %d", len(k.Private.Rounds), rounds)
+			}
+
+			data := []byte("Hello World!")
+			plain, err := k.Decrypt(k.Encrypt(k.NewPlaintext(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := k.FromPlaintext(plain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(data) {
+				t.Errorf("%d rounds: got %q, want %q", rounds, got, data)
+			}
+
+			// public values grow past U with every Round, the stream and container must still read them
+			stream := new(bytes.Buffer)
+			w := NewEncryptWriter(k.Public, stream)
+			if _, err := w.Write(data); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := io.ReadAll(NewDecryptReader(k.Private, stream)); err != nil || !bytes.Equal(got, data) {
+				t.Errorf("%d rounds: DecryptReader got %q, %v, want %q", rounds, got, err, data)
+			}
+
+			container := new(bytes.Buffer)
+			if err := EncryptContainer(k.Public, container, bytes.NewReader(data), int64(len(data))); err != nil {
+				t.Fatal(err)
+			}
+			decrypted := new(bytes.Buffer)
+			if err := DecryptContainer(k.Private, decrypted, container); err != nil || !bytes.Equal(decrypted.Bytes(), data) {
+				t.Errorf("%d rounds: DecryptContainer got %q, %v, want %q", rounds, decrypted.Bytes(), err, data)
+			}
+
+			// the Rounds must survive serialization
+			text, err := k.Private.MarshalText()
+			if err != nil {
+				t.Fatal(err)
+			}
+			private := new(PrivateKey)
+			if err := private.UnmarshalText(text); err != nil {
+				t.Fatal(err)
+			}
+			if BigIntsToStr(NewPublicKey(private, private.Set)) != BigIntsToStr(k.Public) {
+				t.Errorf("reloaded key has a different PublicKey:\n%s", text)
+			}
+		}
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		// the Set sums to 613 < 672, then 13*Set % 672 sums to 2593 < 2953
+		private := &PrivateKey{
+			V:      big.NewInt(13),
+			U:      big.NewInt(672),
+			Rounds: []Round{{V: big.NewInt(1001), U: big.NewInt(2953)}},
+		}
+		k, err := NewKnapsackCustom(1, private, bigInts(3, 5, 9, 18, 38, 75, 155, 310))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 1001*(13*Set[i] % 672) % 2953
+		if got, want := BigIntsToStr(k.Public), "650, 99, 1950, 947, 1343, 2097, 1340, 339"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			round Round
+			want  error
+		}{
+			// the first knapsack sums to 2593
+			{name: "u too small", round: Round{V: big.NewInt(1001), U: big.NewInt(2593)}, want: ErrModulusTooSmall},
+			{name: "v too large", round: Round{V: big.NewInt(2953), U: big.NewInt(2953)}, want: ErrMultiplierRange},
+			{name: "not coprime", round: Round{V: big.NewInt(1000), U: big.NewInt(3000)}, want: ErrNotCoprime},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				private := &PrivateKey{
+					Set:    bigInts(3, 5, 9, 18, 38, 75, 155, 310),
+					V:      big.NewInt(13),
+					U:      big.NewInt(672),
+					Rounds: []Round{tt.round},
+				}
+				if err := private.Validate(); !errors.Is(err, tt.want) {
+					t.Errorf("err = %v, want %v", err, tt.want)
+				}
+			})
+		}
+	})
+}
+
+// TestIteratedAttack compares the lattice Attack against a basic key and the same key with extra Rounds.
+// The Rounds hide the first trapdoor, which is what Shamir's attack needs,
+// but every Round makes the public values larger, so the density falls and the low density lattice
+// Attack, which never looks for a trapdoor, only gets easier.
+func TestIteratedAttack(t *testing.T) {
+	set := bigInts(2, 3, 7, 14, 30, 57)
+	basic, err := NewKnapsackCustom(1, &PrivateKey{V: big.NewInt(41), U: big.NewInt(491)}, append(set, bigInts(120, 251)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iterated := &Knapsack{Private: &PrivateKey{Set: basic.Private.Set, V: basic.Private.V, U: basic.Private.U}}
+	if err := iterated.Private.randomRounds(NewSeededRand([]byte("iterated")), 2); err != nil {
+		t.Fatal(err)
+	}
+	iterated.Public = NewPublicKey(iterated.Private, iterated.Private.Set)
+
+	t.Run("density", func(t *testing.T) {
+		b, i := Analyze(basic.Public), Analyze(iterated.Public)
+		if i.Density >= b.Density {
+			t.Errorf("iterated density %v, want below the basic density %v", i.Density, b.Density)
+		}
+	})
+
+	t.Run("trapdoor", func(t *testing.T) {
+		// undoing just V and U exposes the superincreasing Set of the basic key, but not of the iterated key
+		for _, tt := range []struct {
+			k    *Knapsack
+			want bool
+		}{{k: basic, want: true}, {k: iterated, want: false}} {
+			inverse, err := tt.k.Private.inverse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s, _ := sortedPerm(recoverSet(tt.k.Public, tt.k.Private.U, inverse))
+			if got := s.IsSuperincreasing(); got != tt.want {
+				t.Errorf("%d rounds: recovered set %s superincreasing = %v, want %v", len(tt.k.Private.Rounds), BigIntsToStr(s), got, tt.want)
+			}
+		}
+	})
+
+	t.Run("lattice", func(t *testing.T) {
+		data := []byte("Hello World!")
+		for _, tt := range []struct {
+			k    *Knapsack
+			want bool
+		}{{k: basic, want: false}, {k: iterated, want: true}} {
+			rounds := len(tt.k.Private.Rounds)
+			result, err := Attack(tt.k.Encrypt(tt.k.NewPlaintext(data)), tt.k.Public, AttackOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the basic key is above LagariasOdlyzkoDensity, the iterated one below it
+			if got := result.Recovered(); got != tt.want {
+				t.Errorf("%d rounds: density %v, recovered = %v, want %v", rounds, Analyze(tt.k.Public).Density, got, tt.want)
+			}
+			for _, b := range result.Blocks {
+				if b.Recovered() && b.Block.Cmp(tt.k.NewPlaintext(data)[b.Index]) != 0 {
+					t.Errorf("%d rounds: block %d recovered as %b", rounds, b.Index, b.Block)
+				}
+			}
+		}
+	})
+}
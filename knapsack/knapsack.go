@@ -3,6 +3,7 @@ package knapsack
 import (
 	"crypto/rand"
 	"fmt"
+	"io"
 	"math/big"
 	"strings"
 )
@@ -10,9 +11,27 @@ import (
 // sMax is the largest big.Int to use when generating a Set's random number.
 var sMax = big.NewInt(8)
 
+// PrivateKey is the secret half of a Knapsack key pair.
+// It carries the multiplier V, the modulus U, and the superincreasing Set used to build the PublicKey.
+// Perm is the secret permutation from the original paper: PublicKey[i] is built from Set[Perm[i]],
+// so the PublicKey doesn't reveal which value is largest. A nil Perm keeps the Set's order.
+// Rounds are the extra modular multiplications of the iterated scheme, applied after V and U.
 type PrivateKey struct {
-	V *big.Int
-	U *big.Int
+	Set    Set
+	V      *big.Int
+	U      *big.Int
+	Perm   []int
+	Rounds []Round
+}
+
+// Bits returns the number of bits encrypted per block.
+func (p *PrivateKey) Bits() int {
+	return len(p.Set)
+}
+
+// BlockSize returns the number of whole bytes encrypted per block.
+func (p *PrivateKey) BlockSize() int {
+	return p.Bits() / 8
 }
 
 func validGCD(v, u *big.Int) bool {
@@ -20,239 +39,299 @@ func validGCD(v, u *big.Int) bool {
 	return gcd.Cmp(big.NewInt(1)) == 0
 }
 
+// RandomPrivateKey picks a random U > sum(s) and 0 < V < U with GCD(V, U) = 1 for the superincreasing Set s,
+// and a random Perm.
 func RandomPrivateKey(s Set) (*PrivateKey, error) {
-	pMax := new(big.Int).Set(s[len(s)-1])
-	pMax = pMax.Mul(pMax, big.NewInt(10))
-
-	var err error
-	for {
-		// generate random u > 2*Sn
-		var u *big.Int
-		for {
-			u, err = rand.Int(rand.Reader, pMax)
-			if err != nil {
-				return nil, err
-			}
-
-			// if u > 2 * Sn, stop generating new u values
-			if u.Cmp(new(big.Int).Mul(big.NewInt(2), s[len(s)-1])) == 1 {
-				break
-			}
-		}
-		var v *big.Int
-		for {
-			v, err = rand.Int(rand.Reader, pMax)
-			if err != nil {
-				return nil, err
-			}
-
-			// if GCD(v,u) == 1, stop generating new v values
-			if validGCD(v, u) {
-				break
-			}
-		}
-
-		return &PrivateKey{
-			U: u,
-			V: v,
-		}, nil
-	}
+	return randomPrivateKey(rand.Reader, s)
 }
 
-type Set []*big.Int
-
-func (s Set) IsSuperincreasing() bool {
-	sum := new(big.Int).Set(s[0])
+// RandomPrivateKeyWithRand is RandomPrivateKey, reading its randomness from random.
+func RandomPrivateKeyWithRand(random io.Reader, s Set) (*PrivateKey, error) {
+	return randomPrivateKey(random, s)
+}
 
-	for _, si := range s[1:] {
-		// if s1 <= sum
-		if si.Cmp(sum) != 1 {
-			return false
-		}
-		sum.Add(sum, si)
+func randomPrivateKey(random io.Reader, s Set) (*PrivateKey, error) {
+	if err := validateSet(s); err != nil {
+		return nil, err
 	}
 
-	return true
-}
-
-// randomSetHelper generates a big.Int between [2, sMax+2)
-func randomSetHelper() (*big.Int, error) {
-	i, err := rand.Int(rand.Reader, sMax)
+	pMax := new(big.Int).Mul(s[len(s)-1], big.NewInt(10))
+	v, u, err := randomMultiplier(random, s.Sum(), pMax)
 	if err != nil {
 		return nil, err
 	}
-	i = i.Add(i, big.NewInt(2))
-	return i, nil
-}
 
-// RandomSet returns a new superincreasing Set of size 8 * blockSize.
-// Starting with a value < sMax and increasing
-func RandomSet(blockSize int) (Set, error) {
-	size := 8 * blockSize
-	a := make([]*big.Int, size)
-
-	// start with a random int
-	var err error
-	a[0], err = randomSetHelper()
+	perm, err := randomPerm(random, len(s))
 	if err != nil {
 		return nil, err
 	}
 
-	sum := big.NewInt(0)
-
-	// each step, increase by sum + (r < sMax)
-	for i := 1; i < size; i++ {
-		sum.Add(sum, a[i-1])
+	return &PrivateKey{
+		Set:  s,
+		U:    u,
+		V:    v,
+		Perm: perm,
+	}, nil
+}
 
-		r, err := randomSetHelper()
+// randomMultiplier picks a random sum < u < pMax, and 0 < v < u with GCD(v, u) = 1.
+func randomMultiplier(random io.Reader, sum, pMax *big.Int) (v, u *big.Int, err error) {
+	// generate random u > sum
+	for {
+		u, err = rand.Int(random, pMax)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		a[i] = new(big.Int).Add(sum, r)
+		// if u > sum, stop generating new u values
+		if u.Cmp(sum) == 1 {
+			break
+		}
 	}
 
-	return a, nil
-}
-
-type PublicKey []*big.Int
-
-// NewPublicKey creates a new PublicKey with PrivateKey.V * Set[i] % PrivateKey.U.
-func NewPublicKey(private *PrivateKey, s Set) PublicKey {
-	public := make([]*big.Int, len(s))
+	// generate random 0 < v < u
+	for {
+		v, err = rand.Int(random, u)
+		if err != nil {
+			return nil, nil, err
+		}
 
-	for i, si := range s {
-		ai := new(big.Int).Mul(private.V, si)
-		public[i] = ai.Mod(ai, private.U)
+		// if GCD(v,u) == 1, stop generating new v values
+		if v.Sign() == 1 && validGCD(v, u) {
+			break
+		}
 	}
 
-	return public
+	return v, u, nil
 }
 
-type Plaintext []*big.Int
+type Set []*big.Int
 
-func (k *Knapsack) NewPlaintext(data []byte) Plaintext {
-	if len(data) == 0 {
-		return nil
-	}
+// IsSuperincreasing reports whether every value of s is positive and larger than the sum of the values before it.
+// An empty Set is not superincreasing.
+func (s Set) IsSuperincreasing() bool {
+	return validateSet(s) == nil
+}
 
-	plain := make([]*big.Int, 0)
+// RandomSet returns a new superincreasing Set of size 8 * blockSize.
+// Starting with a value < sMax and increasing
+func RandomSet(blockSize int) (Set, error) {
+	return RandomSetBits(8 * blockSize)
+}
 
-	for i := 0; i < len(data); i += k.BlockSize {
-		end := i + k.BlockSize
-		if end > len(data) {
-			end = len(data)
-		}
+// RandomSetBits returns a new superincreasing Set of size n, for blocks of n bits.
+func RandomSetBits(n int) (Set, error) {
+	return RandomSetBitsWithRand(rand.Reader, n)
+}
 
-		block := make([]byte, k.BlockSize)
-		copy(block, data[i:end])
+// RandomSetWithRand is RandomSet, reading its randomness from random.
+func RandomSetWithRand(random io.Reader, blockSize int) (Set, error) {
+	return RandomSetBitsWithRand(random, 8*blockSize)
+}
 
-		bigInt := new(big.Int).SetBytes(block)
-		plain = append(plain, bigInt)
+// RandomSetBitsWithRand is RandomSetBits, reading its randomness from random.
+func RandomSetBitsWithRand(random io.Reader, n int) (Set, error) {
+	if err := validateBits(n); err != nil {
+		return nil, err
 	}
 
-	return plain
+	return randomSet(random, n, sMax)
 }
 
-func (k *Knapsack) FromPlaintext(plain Plaintext) []byte {
-	if len(plain) == 0 {
-		return nil
-	}
+func randomSet(random io.Reader, size int, maxGap *big.Int) (Set, error) {
+	// a gap between [2, maxGap+2)
+	g := GapGenerator{Min: big.NewInt(2), Max: new(big.Int).Add(maxGap, big.NewInt(1))}
 
-	data := make([]byte, 0)
+	return g.GenerateSet(random, size)
+}
 
-	for _, b := range plain {
-		data = append(data, b.Bytes()...)
-	}
+// PublicKey is the public half of a Knapsack key pair.
+// It holds one value per plaintext bit of a block.
+type PublicKey []*big.Int
 
-	// remove padded 0's
-	clean := make([]byte, 0)
-	for _, d := range data {
-		if d != 0 {
-			clean = append(clean, d)
-		}
-	}
+// Bits returns the number of bits encrypted per block.
+func (p PublicKey) Bits() int {
+	return len(p)
+}
 
-	return clean
+// BlockSize returns the number of whole bytes encrypted per block.
+func (p PublicKey) BlockSize() int {
+	return p.Bits() / 8
+}
+
+// NewPublicKey creates a new PublicKey with PrivateKey.V * Set[PrivateKey.Perm[i]] % PrivateKey.U,
+// multiplied by each of PrivateKey.Rounds in order.
+func NewPublicKey(private *PrivateKey, s Set) PublicKey {
+	return permute(private.intermediate(s, len(private.Rounds)), private.Perm)
 }
 
 type Ciphertext []*big.Int
 
+// Knapsack is a Merkle–Hellman key pair.
 type Knapsack struct {
-	BlockSize int
-	Private   *PrivateKey
-	Set       Set
-	Public    PublicKey
+	Private *PrivateKey
+	Public  PublicKey
+}
+
+// Bits returns the number of bits encrypted per block.
+func (k *Knapsack) Bits() int {
+	return k.Public.Bits()
+}
+
+// BlockSize returns the number of whole bytes encrypted per block.
+func (k *Knapsack) BlockSize() int {
+	return k.Public.BlockSize()
 }
 
 func validateBlockSize(blockSize int) error {
-	if blockSize < 1 || 8 < blockSize {
-		return fmt.Errorf("blockSize must be between [1,8]")
+	if blockSize < 1 {
+		return fmt.Errorf("blockSize must be >= 1")
 	}
 
 	return nil
 }
 
-func newKnapsack(blockSize int, private *PrivateKey, s Set) (*Knapsack, error) {
+func validateBits(n int) error {
+	if n < 1 {
+		return fmt.Errorf("bits must be >= 1")
+	}
+
+	return nil
+}
+
+func newKnapsack(private *PrivateKey) (*Knapsack, error) {
+	if err := private.Validate(); err != nil {
+		return nil, err
+	}
+
 	k := new(Knapsack)
-	k.BlockSize = blockSize
 	k.Private = private
-	k.Set = s
 
-	k.Public = NewPublicKey(k.Private, k.Set)
+	k.Public = NewPublicKey(k.Private, k.Private.Set)
 
 	return k, nil
 }
 
+// Options configures NewKnapsackWithOptions. The zero value matches NewKnapsack.
+type Options struct {
+	// Rand is the source of randomness, crypto/rand.Reader if nil.
+	// Using a deterministic reader makes key generation reproducible.
+	Rand io.Reader
+
+	// MaxGap bounds how much larger than the sum of the previous values each Set value is, sMax if nil.
+	// Each value grows by a random gap between [2, MaxGap+2). It is ignored if Set isn't nil.
+	MaxGap *big.Int
+
+	// Set generates the superincreasing Set, a GapGenerator using MaxGap if nil.
+	Set SetGenerator
+
+	// Rounds is the number of extra Rounds of the iterated scheme, 0 for the basic scheme.
+	Rounds int
+}
+
 func NewKnapsack(blockSize int) (*Knapsack, error) {
+	return NewKnapsackWithOptions(blockSize, Options{})
+}
+
+// NewKnapsackWithOptions creates a random Knapsack configured by opts.
+func NewKnapsackWithOptions(blockSize int, opts Options) (*Knapsack, error) {
 	err := validateBlockSize(blockSize)
 	if err != nil {
 		return nil, err
 	}
 
-	s, err := RandomSet(blockSize)
+	return NewKnapsackBits(8*blockSize, opts)
+}
+
+// NewKnapsackBits creates a random Knapsack encrypting blocks of n bits, configured by opts.
+// n does not need to be a multiple of 8.
+func NewKnapsackBits(n int, opts Options) (*Knapsack, error) {
+	err := validateBits(n)
+	if err != nil {
+		return nil, err
+	}
+
+	random := opts.Rand
+	if random == nil {
+		random = rand.Reader
+	}
+
+	var s Set
+	if opts.Set != nil {
+		s, err = opts.Set.GenerateSet(random, n)
+	} else {
+		maxGap := opts.MaxGap
+		if maxGap == nil {
+			maxGap = sMax
+		}
+		if maxGap.Sign() <= 0 {
+			return nil, fmt.Errorf("MaxGap must be > 0")
+		}
+
+		s, err = randomSet(random, n, maxGap)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if len(s) != n {
+		return nil, fmt.Errorf("%w: generated %d values, want %d", ErrSetLength, len(s), n)
+	}
+
+	if opts.Rounds < 0 {
+		return nil, fmt.Errorf("Rounds must be >= 0")
+	}
 
-	private, err := RandomPrivateKey(s)
+	private, err := randomPrivateKey(random, s)
 	if err != nil {
 		return nil, err
 	}
 
-	return newKnapsack(blockSize, private, s)
+	if err := private.randomRounds(random, opts.Rounds); err != nil {
+		return nil, err
+	}
+
+	return newKnapsack(private)
 }
 
+// NewKnapsackCustom creates a Knapsack from private's V, U, Perm and Rounds and the Set s.
+// It fails if s doesn't have 8*blockSize values, or the key pair isn't valid (see PrivateKey.Validate).
 func NewKnapsackCustom(blockSize int, private *PrivateKey, s Set) (*Knapsack, error) {
 	err := validateBlockSize(blockSize)
 	if err != nil {
 		return nil, err
 	}
 
-	if !validGCD(private.V, private.U) {
-		return nil, fmt.Errorf("GCD(%v, %v) != 1", private.V, private.U)
+	if len(s) != 8*blockSize {
+		return nil, fmt.Errorf("%w: %d values, want %d for block size %d", ErrSetLength, len(s), 8*blockSize, blockSize)
+	}
+
+	// copy so the caller's PrivateKey is left untouched
+	p := &PrivateKey{
+		Set:    s,
+		V:      private.V,
+		U:      private.U,
+		Perm:   private.Perm,
+		Rounds: private.Rounds,
 	}
 
-	return newKnapsack(blockSize, private, s)
+	return newKnapsack(p)
 }
 
-func (k *Knapsack) Encrypt(plain Plaintext) Ciphertext {
-	cipher := make([]*big.Int, 0)
+// Encrypt encrypts plain using only the PublicKey.
+// Each block is treated as exactly Bits bits, where the i-th most significant bit selects PublicKey[i].
+// Bits above that width are ignored.
+func (p PublicKey) Encrypt(plain Plaintext) Ciphertext {
+	size := len(p)
+	cipher := make([]*big.Int, 0, len(plain))
 
 	// loop through every block
 	for _, block := range plain {
 		sum := big.NewInt(0)
 
-		// loop through the bytes in each block (left to right)
-		for i, b := range block.Bytes() {
-			bit := byte(1)
-
-			// loop through plaintext byte (right to left) and increment sum by the matching PublicKey index
-			for j := 7; j >= 0; j-- {
-				if b&bit != 0 {
-					sum.Add(sum, k.Public[(i*8)+j])
-				}
-				bit = bit << 1 // bitshift left by 1
+		// loop through the block's bits (most significant first) and increment sum by the matching PublicKey index
+		for i := 0; i < size; i++ {
+			if block.Bit(size-1-i) == 1 {
+				sum.Add(sum, p[i])
 			}
 		}
 
@@ -262,9 +341,27 @@ func (k *Knapsack) Encrypt(plain Plaintext) Ciphertext {
 	return cipher
 }
 
-func (k *Knapsack) Decrypt(cipher Ciphertext) (Plaintext, error) {
-	v := k.Private.V
-	u := k.Private.U
+// Decrypt decrypts cipher using only the PrivateKey.
+func (p *PrivateKey) Decrypt(cipher Ciphertext) (Plaintext, error) {
+	inverse, err := p.inverse()
+	if err != nil {
+		return nil, err
+	}
+
+	cipher, err = p.unwind(cipher)
+	if err != nil {
+		return nil, err
+	}
+
+	return unpermuteBits(decrypt(p.Set, p.U, inverse, cipher), p.Perm, len(p.Set)), nil
+}
+
+// inverse returns V^-1 mod U.
+func (p *PrivateKey) inverse() (*big.Int, error) {
+	return modInverse(p.V, p.U)
+}
+
+func modInverse(v, u *big.Int) (*big.Int, error) {
 	// if u == 0
 	if u.Cmp(big.NewInt(0)) == 0 {
 		return nil, fmt.Errorf("u == 0")
@@ -276,16 +373,26 @@ func (k *Knapsack) Decrypt(cipher Ciphertext) (Plaintext, error) {
 		return nil, fmt.Errorf("inverse is nil or 0")
 	}
 
-	// recreate Set
-	size := 8 * k.BlockSize
-	s := make([]*big.Int, size)
-	for i := 0; i < size; i++ {
+	return inverse, nil
+}
+
+// recoverSet recreates a Set from public using V^-1 mod U.
+func recoverSet(public PublicKey, u, inverse *big.Int) Set {
+	s := make([]*big.Int, len(public))
+	for i := range public {
 		si := new(big.Int)
-		si = si.Mul(k.Public[i], inverse)
+		si = si.Mul(public[i], inverse)
 		s[i] = si.Mod(si, u)
 	}
 
-	plain := make([]*big.Int, 0)
+	return s
+}
+
+// decrypt solves each block of cipher against the superincreasing Set s.
+// The i-th most significant bit of each Plaintext block matches s[i], the inverse of Encrypt.
+func decrypt(s Set, u, inverse *big.Int, cipher Ciphertext) Plaintext {
+	size := len(s)
+	plain := make([]*big.Int, 0, len(cipher))
 
 	// loop through every block
 	for _, block := range cipher {
@@ -295,22 +402,27 @@ func (k *Knapsack) Decrypt(cipher Ciphertext) (Plaintext, error) {
 
 		sum := big.NewInt(0)
 
-		bit := 0
-
-		// loop through t, subtract si if possible, create binary
+		// loop through t from the largest si, subtract si if possible, create binary
 		for i := size - 1; i >= 0; i-- {
 			// if t >= s[i]
 			if t.Cmp(s[i]) >= 0 {
 				t.Sub(t, s[i])
-				sum.SetBit(sum, bit, 1)
+				sum.SetBit(sum, size-1-i, 1)
 			}
-			bit++
 		}
 
 		plain = append(plain, sum)
 	}
 
-	return plain, nil
+	return plain
+}
+
+func (k *Knapsack) Encrypt(plain Plaintext) Ciphertext {
+	return k.Public.Encrypt(plain)
+}
+
+func (k *Knapsack) Decrypt(cipher Ciphertext) (Plaintext, error) {
+	return k.Private.Decrypt(cipher)
 }
 
 func BigIntsToStr(ints []*big.Int) string {
@@ -1,7 +1,10 @@
 package knapsack
 
 import (
+	"bytes"
 	"crypto/rand"
+	"errors"
+	"fmt"
 	"math/big"
 	mathRand "math/rand/v2"
 	"reflect"
@@ -44,6 +47,18 @@ func TestKnapsack(t *testing.T) {
 				data: []byte("Bat"),
 			},
 		},
+		{
+			name: "leading zeros",
+			args: args{
+				blockSize: 2,
+				private: &PrivateKey{
+					U: big.NewInt(476729),
+					V: big.NewInt(476728),
+				},
+				set:  []int64{8, 17, 29, 56, 118, 234, 464, 931, 1862, 3724, 7448, 14900, 29794, 59591, 119183, 238364},
+				data: []byte{0, 0, 0, 'B', 0, 0},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -76,7 +91,10 @@ func TestKnapsack(t *testing.T) {
 				t.FailNow()
 			}
 
-			got := k.FromPlaintext(newPlain)
+			got, err := k.FromPlaintext(newPlain)
+			if err != nil {
+				t.Fatal(err)
+			}
 
 			if !reflect.DeepEqual(got, tt.args.data) {
 				t.Errorf("got %#v, want %#v", got, tt.args.data)
@@ -90,9 +108,11 @@ func TestRandomKnapsack(t *testing.T) {
 		for range 100 {
 			r := (mathRand.Int() % 8) + 1
 
-			k, err := NewKnapsack(r)
+			// a failing key can be replayed with NewSeededRand and the logged seed
+			seed := rand.Text()
+			k, err := NewKnapsackWithOptions(r, Options{Rand: NewSeededRand([]byte(seed))})
 			if err != nil {
-				t.Fatal(err)
+				t.Fatalf("seed %q: %v", seed, err)
 			}
 
 			data := []byte(rand.Text())
@@ -103,10 +123,57 @@ func TestRandomKnapsack(t *testing.T) {
 
 			newPlain, err := k.Decrypt(cipher)
 			if err != nil {
-				t.FailNow()
+				t.Fatalf("seed %q: %v", seed, err)
 			}
 
-			got := k.FromPlaintext(newPlain)
+			got, err := k.FromPlaintext(newPlain)
+			if err != nil {
+				t.Fatalf("seed %q: %v", seed, err)
+			}
+
+			if !reflect.DeepEqual(got, data) {
+				t.Errorf("seed %q, block size %d: got %#v, want %#v", seed, r, got, data)
+			}
+		}
+	})
+}
+
+func TestSplitKeys(t *testing.T) {
+	t.Run("random", func(t *testing.T) {
+		for range 100 {
+			r := (mathRand.Int() % 8) + 1
+
+			k, err := NewKnapsack(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// only hand each side its own half of the key pair
+			public := append(PublicKey{}, k.Public...)
+			private := &PrivateKey{
+				Set:  k.Private.Set,
+				V:    k.Private.V,
+				U:    k.Private.U,
+				Perm: k.Private.Perm,
+			}
+
+			if public.BlockSize() != r {
+				t.Fatalf("public.BlockSize() = %d, want %d", public.BlockSize(), r)
+			}
+
+			data := []byte(rand.Text())
+
+			cipher := public.Encrypt(NewPlaintext(public.BlockSize(), data))
+
+			newPlain, err := private.Decrypt(cipher)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := FromPlaintext(public.BlockSize(), newPlain)
+			if err != nil {
+				t.Fatal(err)
+			}
 
 			if !reflect.DeepEqual(got, data) {
 				t.Errorf("got %#v, want %#v", got, data)
@@ -114,3 +181,254 @@ func TestRandomKnapsack(t *testing.T) {
 		}
 	})
 }
+
+func TestPlaintext(t *testing.T) {
+	tests := []struct {
+		name      string
+		blockSize int
+		data      []byte
+	}{
+		{name: "empty", blockSize: 1, data: []byte{}},
+		{name: "zeros", blockSize: 2, data: []byte{0, 0, 0}},
+		{name: "trailing zero", blockSize: 3, data: []byte{1, 2, 0}},
+		{name: "marker", blockSize: 4, data: []byte{0x80, 0, 0x80}},
+		{name: "full block", blockSize: 4, data: []byte{0, 1, 2, 3}},
+		{name: "utf-16", blockSize: 8, data: []byte{0, 'B', 0, 'a', 0, 't'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := NewPlaintext(tt.blockSize, tt.data)
+
+			if len(plain) != len(tt.data)/tt.blockSize+1 {
+				t.Errorf("len(plain) = %d, want %d", len(plain), len(tt.data)/tt.blockSize+1)
+			}
+
+			got, err := FromPlaintext(tt.blockSize, plain)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("got %#v, want %#v", got, tt.data)
+			}
+		})
+	}
+}
+
+func TestFromPlaintextInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		blockSize int
+		plain     Plaintext
+	}{
+		{name: "empty", blockSize: 1, plain: Plaintext{}},
+		{name: "no marker", blockSize: 2, plain: Plaintext{big.NewInt(0x0102)}},
+		{name: "all zero", blockSize: 2, plain: Plaintext{big.NewInt(0x0102), big.NewInt(0)}},
+		{name: "marker in earlier block", blockSize: 2, plain: Plaintext{big.NewInt(0x0180), big.NewInt(0)}},
+		{name: "too large", blockSize: 1, plain: Plaintext{big.NewInt(0x0180)}},
+		{name: "no block size", blockSize: 0, plain: Plaintext{big.NewInt(0x80)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := FromPlaintext(tt.blockSize, tt.plain); err == nil {
+				t.Errorf("FromPlaintext() = %#v, want error", got)
+			}
+		})
+	}
+}
+
+func TestEncryptFixedWidth(t *testing.T) {
+	t.Run("random", func(t *testing.T) {
+		for range 100 {
+			r := (mathRand.Int() % 8) + 1
+
+			k, err := NewKnapsack(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// random blocks where the first z bytes are 0, including the all-zero block
+			plain := make(Plaintext, 0, r+1)
+			for z := 0; z <= r; z++ {
+				block := make([]byte, r)
+				for i := z; i < r; i++ {
+					block[i] = byte(mathRand.IntN(256))
+				}
+				if z < r {
+					block[z] |= 1 // make sure the first non-zero byte is really at z
+				}
+				plain = append(plain, new(big.Int).SetBytes(block))
+			}
+
+			cipher := k.Public.Encrypt(plain)
+
+			for i, block := range plain {
+				// the i-th most significant bit of the block selects Public[i]
+				want := big.NewInt(0)
+				for j := range k.Public {
+					if block.Bit(len(k.Public)-1-j) == 1 {
+						want.Add(want, k.Public[j])
+					}
+				}
+
+				if cipher[i].Cmp(want) != 0 {
+					t.Errorf("block %x: cipher = %v, want %v", block.Bytes(), cipher[i], want)
+				}
+			}
+
+			newPlain, err := k.Private.Decrypt(cipher)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := range plain {
+				if newPlain[i].Cmp(plain[i]) != 0 {
+					t.Errorf("got %v, want %v", newPlain, plain)
+					break
+				}
+			}
+		}
+	})
+}
+
+// bigInts converts ints to []*big.Int.
+func bigInts(ints ...int64) []*big.Int {
+	b := make([]*big.Int, len(ints))
+	for i, v := range ints {
+		b[i] = big.NewInt(v)
+	}
+
+	return b
+}
+
+func TestNewKnapsackWithOptions(t *testing.T) {
+	t.Run("deterministic", func(t *testing.T) {
+		k1, err := NewKnapsackWithOptions(4, Options{Rand: mathRand.NewChaCha8([32]byte{1})})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		k2, err := NewKnapsackWithOptions(4, Options{Rand: mathRand.NewChaCha8([32]byte{1})})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if BigIntsToStr(k1.Public) != BigIntsToStr(k2.Public) || k1.Private.V.Cmp(k2.Private.V) != 0 || k1.Private.U.Cmp(k2.Private.U) != 0 {
+			t.Errorf("same seed gave different keys: %v, %v", k1.Private, k2.Private)
+		}
+	})
+
+	t.Run("max gap", func(t *testing.T) {
+		// a gap between [2, 3) is always 2
+		k, err := NewKnapsackWithOptions(1, Options{MaxGap: big.NewInt(1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := "2, 4, 8, 16, 32, 64, 128, 256"
+		if got := BigIntsToStr(k.Private.Set); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("invalid max gap", func(t *testing.T) {
+		if _, err := NewKnapsackWithOptions(1, Options{MaxGap: big.NewInt(0)}); err == nil {
+			t.Error("want error")
+		}
+	})
+}
+
+func TestKnapsackBits(t *testing.T) {
+	// realistic sizes from the literature, and sizes that aren't whole bytes
+	for _, n := range []int{1, 7, 13, 64, 100, 128, 256} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			k, err := NewKnapsackBits(n, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if k.Bits() != n || len(k.Public) != n || len(k.Private.Set) != n {
+				t.Fatalf("Bits() = %d, want %d", k.Bits(), n)
+			}
+			if !k.Private.Set.IsSuperincreasing() {
+				t.Fatalf("Set is not superincreasing: %s", BigIntsToStr(k.Private.Set))
+			}
+
+			for _, data := range [][]byte{{}, {0}, []byte(rand.Text()), bytes.Repeat([]byte{0xff}, 65)} {
+				plain := k.NewPlaintext(data)
+				if len(plain) != 8*len(data)/n+1 {
+					t.Errorf("len(plain) = %d, want %d", len(plain), 8*len(data)/n+1)
+				}
+
+				newPlain, err := k.Decrypt(k.Encrypt(plain))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := k.FromPlaintext(newPlain)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(got, data) {
+					t.Errorf("got %#v, want %#v", got, data)
+				}
+			}
+		})
+	}
+}
+
+func TestPlaintextBits(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		data []byte
+		want []int64
+	}{
+		{name: "empty", n: 3, data: []byte{}, want: []int64{0b100}},
+		{name: "1 bit", n: 1, data: []byte{0b10100000}, want: []int64{1, 0, 1, 0, 0, 0, 0, 0, 1}},
+		{name: "padding fills block", n: 3, data: []byte{0xff}, want: []int64{0b111, 0b111, 0b111}},
+		{name: "padding spills", n: 4, data: []byte{0xff}, want: []int64{0b1111, 0b1111, 0b1000}},
+		{name: "across bytes", n: 12, data: []byte{0x12, 0x34, 0x56}, want: []int64{0x123, 0x456, 0x800}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := NewPlaintextBits(tt.n, tt.data)
+			if BigIntsToStr(plain) != BigIntsToStr(bigInts(tt.want...)) {
+				t.Errorf("got %s, want %s", BigIntsToStr(plain), BigIntsToStr(bigInts(tt.want...)))
+			}
+
+			got, err := FromPlaintextBits(tt.n, plain)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("got %#v, want %#v", got, tt.data)
+			}
+		})
+	}
+
+	t.Run("not whole bytes", func(t *testing.T) {
+		// 0b101 leaves 2 bits after its padding is removed
+		if got, err := FromPlaintextBits(3, Plaintext{big.NewInt(0b101)}); !errors.Is(err, ErrInvalidPadding) {
+			t.Errorf("FromPlaintextBits() = %#v, %v, want %v", got, err, ErrInvalidPadding)
+		}
+	})
+
+	t.Run("no bits", func(t *testing.T) {
+		for _, n := range []int{0, -1} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("NewPlaintextBits(%d) didn't panic", n)
+					}
+				}()
+				NewPlaintextBits(n, []byte{1})
+			}()
+		}
+	})
+}
@@ -0,0 +1,302 @@
+package knapsack
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// NaccacheSternPublicKey is the public half of a NaccacheStern key pair.
+// It holds one value per plaintext bit of a block, V[i] = Primes[i]^(1/S) mod P.
+type NaccacheSternPublicKey struct {
+	P *big.Int   `json:"p"`
+	V []*big.Int `json:"v"`
+}
+
+// NaccacheSternPrivateKey is the secret half of a NaccacheStern key pair.
+// The product of the small Primes must be less than the prime P, and S must be invertible mod P - 1.
+type NaccacheSternPrivateKey struct {
+	P      *big.Int   `json:"p"`
+	S      *big.Int   `json:"s"`
+	Primes []*big.Int `json:"primes"`
+}
+
+// NaccacheStern is a Naccache–Stern key pair, the multiplicative knapsack cryptosystem.
+// A block is encrypted as the product of the NaccacheSternPublicKey's values selected by its bits, mod P.
+// Raising it to the secret S gives the product of the matching small primes, which is smaller than P,
+// so factoring it over the Primes recovers the bits.
+type NaccacheStern struct {
+	Private *NaccacheSternPrivateKey `json:"private"`
+	Public  *NaccacheSternPublicKey  `json:"public"`
+}
+
+// firstPrimes returns the n smallest primes.
+func firstPrimes(n int) []*big.Int {
+	primes := make([]*big.Int, 0, n)
+	for i := int64(2); len(primes) < n; i++ {
+		if big.NewInt(i).ProbablyPrime(20) {
+			primes = append(primes, big.NewInt(i))
+		}
+	}
+
+	return primes
+}
+
+// randomPrime returns a random prime of exactly bits bits, for bits >= 2.
+// Unlike crypto/rand.Prime, it only reads from random, so seeded key generation is reproducible.
+func randomPrime(random io.Reader, bits int) (*big.Int, error) {
+	lo := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+	hi := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	hi.Sub(hi, big.NewInt(1))
+
+	for {
+		p, err := randomRange(random, lo, hi)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.ProbablyPrime(20) {
+			return p, nil
+		}
+	}
+}
+
+// NewNaccacheStern creates a random NaccacheStern key pair encrypting blocks of n bits, with the n smallest primes.
+func NewNaccacheStern(n int) (*NaccacheStern, error) {
+	return NewNaccacheSternWithRand(rand.Reader, n)
+}
+
+// NewNaccacheSternWithRand is NewNaccacheStern, reading its randomness from random.
+func NewNaccacheSternWithRand(random io.Reader, n int) (*NaccacheStern, error) {
+	if err := validateBits(n); err != nil {
+		return nil, err
+	}
+
+	primes := firstPrimes(n)
+
+	// any prime of one more bit than the product is larger than it
+	p, err := randomPrime(random, product(primes).BitLen()+1)
+	if err != nil {
+		return nil, err
+	}
+
+	// generate random 1 < s < p - 1 with GCD(s, p - 1) = 1
+	pMinus1 := new(big.Int).Sub(p, big.NewInt(1))
+	var s *big.Int
+	for {
+		s, err = randomRange(random, big.NewInt(2), new(big.Int).Sub(pMinus1, big.NewInt(1)))
+		if err != nil {
+			return nil, err
+		}
+
+		if validGCD(s, pMinus1) {
+			break
+		}
+	}
+
+	private := &NaccacheSternPrivateKey{P: p, S: s, Primes: primes}
+	public, err := NewNaccacheSternPublicKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NaccacheStern{Private: private, Public: public}, nil
+}
+
+// NewNaccacheSternPublicKey creates a new NaccacheSternPublicKey with Primes[i]^(S^-1 mod P-1) mod P.
+func NewNaccacheSternPublicKey(private *NaccacheSternPrivateKey) (*NaccacheSternPublicKey, error) {
+	if err := private.Validate(); err != nil {
+		return nil, err
+	}
+
+	inverse, err := modInverse(private.S, new(big.Int).Sub(private.P, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+
+	v := make([]*big.Int, len(private.Primes))
+	for i, pi := range private.Primes {
+		v[i] = new(big.Int).Exp(pi, inverse, private.P)
+	}
+
+	return &NaccacheSternPublicKey{P: private.P, V: v}, nil
+}
+
+// product returns the product of every value in xs.
+func product(xs []*big.Int) *big.Int {
+	prod := big.NewInt(1)
+	for _, x := range xs {
+		prod.Mul(prod, x)
+	}
+
+	return prod
+}
+
+// Validate checks that the Primes are distinct primes whose product is less than the prime P,
+// and that 1 < S < P - 1 with GCD(S, P - 1) = 1.
+func (p *NaccacheSternPrivateKey) Validate() error {
+	if len(p.Primes) == 0 {
+		return fmt.Errorf("no primes")
+	}
+
+	seen := map[string]bool{}
+	for i, pi := range p.Primes {
+		if pi == nil || !pi.ProbablyPrime(20) || seen[pi.String()] {
+			return fmt.Errorf("Primes[%d] = %v is not a prime, or repeated", i, pi)
+		}
+		seen[pi.String()] = true
+	}
+
+	if p.P == nil || !p.P.ProbablyPrime(20) {
+		return fmt.Errorf("P = %v is not a prime", p.P)
+	}
+
+	if prod := product(p.Primes); p.P.Cmp(prod) != 1 {
+		return fmt.Errorf("P = %v is not larger than the product of the primes %v", p.P, prod)
+	}
+
+	pMinus1 := new(big.Int).Sub(p.P, big.NewInt(1))
+	if p.S == nil || p.S.Cmp(big.NewInt(1)) != 1 || p.S.Cmp(pMinus1) != -1 || !validGCD(p.S, pMinus1) {
+		return fmt.Errorf("S = %v is not between (1, P - 1) and coprime with P - 1 = %v", p.S, pMinus1)
+	}
+
+	return nil
+}
+
+// Validate checks that P is a prime and that every value of V is between (0, P).
+func (p *NaccacheSternPublicKey) Validate() error {
+	if p.P == nil || !p.P.ProbablyPrime(20) {
+		return fmt.Errorf("P = %v is not a prime", p.P)
+	}
+
+	if len(p.V) == 0 {
+		return fmt.Errorf("no values")
+	}
+
+	for i, v := range p.V {
+		if v == nil || v.Sign() <= 0 || v.Cmp(p.P) != -1 {
+			return fmt.Errorf("V[%d] = %v is not between (0, %v)", i, v, p.P)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that k.Private and k.Public are valid and that k.Public was derived from k.Private.
+func (k *NaccacheStern) Validate() error {
+	if err := k.Private.Validate(); err != nil {
+		return err
+	}
+	if err := k.Public.Validate(); err != nil {
+		return err
+	}
+
+	want, err := NewNaccacheSternPublicKey(k.Private)
+	if err != nil {
+		return err
+	}
+
+	if k.Public.P.Cmp(want.P) != 0 || len(k.Public.V) != len(want.V) {
+		return fmt.Errorf("%w: public P = %v with %d values, private P = %v with %d primes",
+			ErrKeyMismatch, k.Public.P, len(k.Public.V), k.Private.P, len(k.Private.Primes))
+	}
+
+	for i := range want.V {
+		if k.Public.V[i].Cmp(want.V[i]) != 0 {
+			return fmt.Errorf("%w: V[%d] = %v, want %v", ErrKeyMismatch, i, k.Public.V[i], want.V[i])
+		}
+	}
+
+	return nil
+}
+
+// Bits returns the number of bits encrypted per block.
+func (p *NaccacheSternPublicKey) Bits() int {
+	return len(p.V)
+}
+
+// Bits returns the number of bits encrypted per block.
+func (p *NaccacheSternPrivateKey) Bits() int {
+	return len(p.Primes)
+}
+
+// Encrypt encrypts plain using only the NaccacheSternPublicKey.
+// Each block is treated as exactly Bits bits, where the i-th most significant bit selects V[i].
+// Bits above that width are ignored.
+func (p *NaccacheSternPublicKey) Encrypt(plain Plaintext) Ciphertext {
+	size := len(p.V)
+	cipher := make(Ciphertext, 0, len(plain))
+
+	for _, block := range plain {
+		prod := big.NewInt(1)
+		for i := 0; i < size; i++ {
+			if block.Bit(size-1-i) == 1 {
+				prod.Mul(prod, p.V[i])
+				prod.Mod(prod, p.P)
+			}
+		}
+
+		cipher = append(cipher, prod)
+	}
+
+	return cipher
+}
+
+// Decrypt decrypts cipher using only the NaccacheSternPrivateKey.
+func (p *NaccacheSternPrivateKey) Decrypt(cipher Ciphertext) (Plaintext, error) {
+	size := len(p.Primes)
+	plain := make(Plaintext, 0, len(cipher))
+
+	for b, block := range cipher {
+		// c^S = Π Primes[i]^bit mod P, which is the product itself since it is less than P
+		t := new(big.Int).Exp(block, p.S, p.P)
+
+		m := big.NewInt(0)
+		r := new(big.Int)
+		for i, pi := range p.Primes {
+			q, _ := new(big.Int).QuoRem(t, pi, r)
+			if r.Sign() == 0 {
+				t = q
+				m.SetBit(m, size-1-i, 1)
+			}
+		}
+
+		// anything left over wasn't a product of distinct Primes
+		if t.Cmp(big.NewInt(1)) != 0 {
+			return nil, fmt.Errorf("%w: block %d is not a product of distinct primes", ErrInvalidCiphertext, b)
+		}
+
+		plain = append(plain, m)
+	}
+
+	return plain, nil
+}
+
+// Bits returns the number of bits encrypted per block, from whichever half of the key pair is set, or 0 for neither.
+func (k *NaccacheStern) Bits() int {
+	switch {
+	case k.Public != nil:
+		return k.Public.Bits()
+	case k.Private != nil:
+		return k.Private.Bits()
+	default:
+		return 0
+	}
+}
+
+func (k *NaccacheStern) Encrypt(plain Plaintext) Ciphertext {
+	return k.Public.Encrypt(plain)
+}
+
+func (k *NaccacheStern) Decrypt(cipher Ciphertext) (Plaintext, error) {
+	return k.Private.Decrypt(cipher)
+}
+
+func (k *NaccacheStern) NewPlaintext(data []byte) Plaintext {
+	return NewPlaintextBits(k.Bits(), data)
+}
+
+func (k *NaccacheStern) FromPlaintext(plain Plaintext) ([]byte, error) {
+	return FromPlaintextBits(k.Bits(), plain)
+}
@@ -0,0 +1,131 @@
+package knapsack
+
+import (
+	"errors"
+	"math/big"
+	mathRand "math/rand/v2"
+	"testing"
+)
+
+func TestNaccacheStern(t *testing.T) {
+	t.Run("random", func(t *testing.T) {
+		for _, n := range []int{1, 2, 7, 8, 13, 64, 100, 128} {
+			k, err := NewNaccacheStern(n)
+			if err != nil {
+				t.Fatalf("n=%d: %v", n, err)
+			}
+			if k.Bits() != n {
+				t.Errorf("Bits() = %d, want %d", k.Bits(), n)
+			}
+
+			data := make([]byte, mathRand.IntN(100))
+			for i := range data {
+				data[i] = byte(mathRand.IntN(256))
+			}
+
+			plain, err := k.Decrypt(k.Encrypt(k.NewPlaintext(data)))
+			if err != nil {
+				t.Fatalf("n=%d: %v", n, err)
+			}
+
+			got, err := k.FromPlaintext(plain)
+			if err != nil {
+				t.Fatalf("n=%d: %v", n, err)
+			}
+			if string(got) != string(data) {
+				t.Errorf("n=%d: got %v, want %v", n, got, data)
+			}
+
+			// V[i]^S = Primes[i] mod P
+			for i, v := range k.Public.V {
+				if got := new(big.Int).Exp(v, k.Private.S, k.Private.P); got.Cmp(k.Private.Primes[i]) != 0 {
+					t.Errorf("n=%d: V[%d]^S = %v, want %v", n, i, got, k.Private.Primes[i])
+				}
+			}
+		}
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		// 2*3*5*7 = 210 < 211, and S = 11 has the inverse 191 mod 210: 11*191 = 2101
+		private := &NaccacheSternPrivateKey{P: big.NewInt(211), S: big.NewInt(11), Primes: bigInts(2, 3, 5, 7)}
+		public, err := NewNaccacheSternPublicKey(private)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Primes[i]^191 % 211
+		want := make([]*big.Int, 4)
+		for i, pi := range private.Primes {
+			want[i] = new(big.Int).Exp(pi, big.NewInt(191), big.NewInt(211))
+		}
+		if BigIntsToStr(public.V) != BigIntsToStr(want) {
+			t.Errorf("got %s, want %s", BigIntsToStr(public.V), BigIntsToStr(want))
+		}
+
+		// 0b1011 selects 2, 5 and 7
+		cipher := public.Encrypt(Plaintext{big.NewInt(0b1011)})
+		if got := new(big.Int).Exp(cipher[0], private.S, private.P); got.Int64() != 70 {
+			t.Errorf("c^S = %v, want 70", got)
+		}
+
+		plain, err := private.Decrypt(cipher)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain[0].Int64() != 0b1011 {
+			t.Errorf("got %b, want 1011", plain[0])
+		}
+	})
+
+	t.Run("seeded", func(t *testing.T) {
+		k1, err := NewNaccacheSternWithRand(NewSeededRand([]byte("naccache-stern")), 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k2, err := NewNaccacheSternWithRand(NewSeededRand([]byte("naccache-stern")), 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if k1.Private.P.Cmp(k2.Private.P) != 0 || k1.Private.S.Cmp(k2.Private.S) != 0 {
+			t.Errorf("same seed gave P=%v S=%v and P=%v S=%v", k1.Private.P, k1.Private.S, k2.Private.P, k2.Private.S)
+		}
+	})
+
+	t.Run("invalid ciphertext", func(t *testing.T) {
+		k, err := NewNaccacheStern(8)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// V[0]^2 decrypts to 2*2, which repeats a prime
+		c := new(big.Int).Exp(k.Public.V[0], big.NewInt(2), k.Public.P)
+		if _, err := k.Decrypt(Ciphertext{c}); !errors.Is(err, ErrInvalidCiphertext) {
+			t.Errorf("err = %v, want %v", err, ErrInvalidCiphertext)
+		}
+	})
+
+	t.Run("invalid private key", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			private *NaccacheSternPrivateKey
+		}{
+			{name: "no primes", private: &NaccacheSternPrivateKey{P: big.NewInt(211), S: big.NewInt(11)}},
+			{name: "not prime", private: &NaccacheSternPrivateKey{P: big.NewInt(211), S: big.NewInt(11), Primes: bigInts(2, 4)}},
+			{name: "repeated prime", private: &NaccacheSternPrivateKey{P: big.NewInt(211), S: big.NewInt(11), Primes: bigInts(2, 3, 3)}},
+			{name: "p not prime", private: &NaccacheSternPrivateKey{P: big.NewInt(221), S: big.NewInt(11), Primes: bigInts(2, 3, 5, 7)}},
+			{name: "p too small", private: &NaccacheSternPrivateKey{P: big.NewInt(199), S: big.NewInt(11), Primes: bigInts(2, 3, 5, 7)}},
+			{name: "s not coprime", private: &NaccacheSternPrivateKey{P: big.NewInt(211), S: big.NewInt(3), Primes: bigInts(2, 3, 5, 7)}},
+			{name: "s too large", private: &NaccacheSternPrivateKey{P: big.NewInt(211), S: big.NewInt(210), Primes: bigInts(2, 3, 5, 7)}},
+			{name: "s nil", private: &NaccacheSternPrivateKey{P: big.NewInt(211), Primes: bigInts(2, 3, 5, 7)}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if err := tt.private.Validate(); err == nil {
+					t.Errorf("err = nil")
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,231 @@
+package knapsack
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"io"
+)
+
+const (
+	pemEncryptedPrivateKey = "ENCRYPTED KNAPSACK PRIVATE KEY"
+
+	// encryptedVersion is the version of the passphrase protected format.
+	encryptedVersion = 1
+
+	// argon2Time, argon2Memory (in KiB) and argon2Threads are the Argon2id cost parameters of every key,
+	// the second recommended option of RFC 9106.
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	saltSize      = 16
+	aesKeySize    = 32
+)
+
+// encryptedMagic starts every passphrase protected PrivateKey.
+var encryptedMagic = []byte("KNAPENC")
+
+// ErrIncorrectPassphrase is returned when a PrivateKey can't be decrypted with the given passphrase.
+var ErrIncorrectPassphrase = errors.New("incorrect passphrase")
+
+// newGCM derives an AES-256-GCM cipher from passphrase with Argon2id.
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), salt, argon2Time, argon2Memory, argon2Threads, aesKeySize)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// EncryptPrivateKey serializes private and seals it with a key derived from passphrase.
+//
+// Format: "KNAPENC" | version byte | uvarint time | uvarint memory | threads byte | salt | nonce | AES-GCM(private.MarshalBinary()),
+// where time, memory and threads are the Argon2id parameters and everything before the sealed key is authenticated too.
+func EncryptPrivateKey(private *PrivateKey, passphrase string) ([]byte, error) {
+	data, err := private.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return seal(data, passphrase)
+}
+
+// seal seals data with a key derived from passphrase, in the format of EncryptPrivateKey.
+func seal(data []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	header := append([]byte{}, encryptedMagic...)
+	header = append(header, encryptedVersion)
+	header = binary.AppendUvarint(header, argon2Time)
+	header = binary.AppendUvarint(header, argon2Memory)
+	header = append(header, argon2Threads)
+	header = append(header, salt...)
+	header = append(header, nonce...)
+
+	return gcm.Seal(header, nonce, data, header), nil
+}
+
+// DecryptPrivateKey opens a PrivateKey sealed by EncryptPrivateKey.
+// ErrIncorrectPassphrase is returned if passphrase is wrong or data was modified.
+func DecryptPrivateKey(data []byte, passphrase string) (*PrivateKey, error) {
+	plain, err := unseal(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	private := new(PrivateKey)
+	if err := private.UnmarshalBinary(plain); err != nil {
+		return nil, err
+	}
+
+	return private, nil
+}
+
+// unseal opens data sealed by seal.
+// Only the Argon2id parameters seal writes are accepted, so a crafted key can't make it use more time or memory.
+func unseal(data []byte, passphrase string) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedMagic) || len(data) < len(encryptedMagic)+1 {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidEncoding)
+	}
+
+	if version := data[len(encryptedMagic)]; version != encryptedVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidEncoding, version)
+	}
+
+	r := bytes.NewReader(data[len(encryptedMagic)+1:])
+	passes, err := binary.ReadUvarint(r)
+	if err != nil || passes != argon2Time {
+		return nil, fmt.Errorf("%w: unsupported Argon2id time", ErrInvalidEncoding)
+	}
+	memory, err := binary.ReadUvarint(r)
+	if err != nil || memory != argon2Memory {
+		return nil, fmt.Errorf("%w: unsupported Argon2id memory", ErrInvalidEncoding)
+	}
+	threads, err := r.ReadByte()
+	if err != nil || threads != argon2Threads {
+		return nil, fmt.Errorf("%w: unsupported Argon2id threads", ErrInvalidEncoding)
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(r, salt); err != nil {
+		return nil, fmt.Errorf("%w: missing salt", ErrInvalidEncoding)
+	}
+
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(r, nonce); err != nil {
+		return nil, fmt.Errorf("%w: missing nonce", ErrInvalidEncoding)
+	}
+
+	header := data[:len(data)-r.Len()]
+
+	plain, err := gcm.Open(nil, nonce, data[len(header):], header)
+	if err != nil {
+		return nil, ErrIncorrectPassphrase
+	}
+
+	return plain, nil
+}
+
+// WriteEncryptedPrivateKeyPEM writes private, sealed with passphrase, as an "ENCRYPTED KNAPSACK PRIVATE KEY" PEM block.
+func WriteEncryptedPrivateKeyPEM(w io.Writer, private *PrivateKey, passphrase string) error {
+	data, err := EncryptPrivateKey(private, passphrase)
+	if err != nil {
+		return err
+	}
+
+	return writePEM(w, pemEncryptedPrivateKey, private.Bits(), data)
+}
+
+// ReadEncryptedPrivateKeyPEM reads a PrivateKey written by WriteEncryptedPrivateKeyPEM.
+// ErrIncorrectPassphrase is returned if passphrase is wrong.
+func ReadEncryptedPrivateKeyPEM(r io.Reader, passphrase string) (*PrivateKey, error) {
+	block, err := readPEM(r, pemEncryptedPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPEMMerkleHellman(block); err != nil {
+		return nil, err
+	}
+
+	private, err := DecryptPrivateKey(block.Bytes, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPEMBits(block, private.Bits()); err != nil {
+		return nil, err
+	}
+
+	return private, nil
+}
+
+// WriteEncryptedCryptosystemPrivateKeyPEM writes the key pair c, sealed with passphrase like EncryptPrivateKey,
+// as an "ENCRYPTED KNAPSACK PRIVATE KEY" PEM block. The Scheme header is only written for schemes other than Merkle–Hellman.
+func WriteEncryptedCryptosystemPrivateKeyPEM(w io.Writer, c Cryptosystem, passphrase string) error {
+	data, err := c.MarshalPrivateKey()
+	if err != nil {
+		return err
+	}
+
+	sealed, err := seal(data, passphrase)
+	if err != nil {
+		return err
+	}
+
+	return writeSchemePEM(w, pemEncryptedPrivateKey, c.Scheme(), c.Bits(), sealed)
+}
+
+// ReadEncryptedCryptosystemPrivateKeyPEM reads a key pair written by WriteEncryptedCryptosystemPrivateKeyPEM
+// or WriteEncryptedPrivateKeyPEM. ErrIncorrectPassphrase is returned if passphrase is wrong.
+func ReadEncryptedCryptosystemPrivateKeyPEM(r io.Reader, passphrase string) (Cryptosystem, error) {
+	block, err := readPEM(r, pemEncryptedPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := pemCryptosystem(block)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := unseal(block.Bytes, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.UnmarshalPrivateKey(data); err != nil {
+		return nil, err
+	}
+
+	if err := checkPEMBits(block, c.Bits()); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,109 @@
+package knapsack
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncryptPrivateKey(t *testing.T) {
+	k, err := NewKnapsack(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := EncryptPrivateKey(k.Private, "correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(data, k.Private.U.Bytes()) {
+		t.Errorf("sealed key contains U in the clear")
+	}
+
+	t.Run("correct", func(t *testing.T) {
+		private, err := DecryptPrivateKey(data, "correct horse battery staple")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if private.V.Cmp(k.Private.V) != 0 || private.U.Cmp(k.Private.U) != 0 || BigIntsToStr(private.Set) != BigIntsToStr(k.Private.Set) {
+			t.Errorf("got %v, want %v", private, k.Private)
+		}
+	})
+
+	t.Run("incorrect", func(t *testing.T) {
+		if _, err := DecryptPrivateKey(data, "Tr0ub4dor&3"); !errors.Is(err, ErrIncorrectPassphrase) {
+			t.Errorf("err = %v, want %v", err, ErrIncorrectPassphrase)
+		}
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		modified := bytes.Clone(data)
+		modified[len(modified)-1] ^= 1
+
+		if _, err := DecryptPrivateKey(modified, "correct horse battery staple"); !errors.Is(err, ErrIncorrectPassphrase) {
+			t.Errorf("err = %v, want %v", err, ErrIncorrectPassphrase)
+		}
+	})
+
+	t.Run("other parameters", func(t *testing.T) {
+		// only the Argon2id parameters EncryptPrivateKey writes are accepted
+		header := len(encryptedMagic) + 1
+		for _, i := range []int{header, header + 1, header + 4} {
+			modified := bytes.Clone(data)
+			modified[i]++
+
+			if _, err := DecryptPrivateKey(modified, "correct horse battery staple"); !errors.Is(err, ErrInvalidEncoding) {
+				t.Errorf("byte %d: err = %v, want %v", i, err, ErrInvalidEncoding)
+			}
+		}
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		modified := bytes.Clone(data)
+		modified[len(encryptedMagic)] = encryptedVersion + 1
+
+		if _, err := DecryptPrivateKey(modified, "correct horse battery staple"); !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("err = %v, want %v", err, ErrInvalidEncoding)
+		}
+	})
+
+	t.Run("not encrypted", func(t *testing.T) {
+		plain, _ := k.Private.MarshalBinary()
+
+		if _, err := DecryptPrivateKey(plain, "correct horse battery staple"); !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("err = %v, want %v", err, ErrInvalidEncoding)
+		}
+	})
+}
+
+func TestEncryptedPrivateKeyPEM(t *testing.T) {
+	k, err := NewKnapsack(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteEncryptedPrivateKeyPEM(buf, k.Private, "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	pem := buf.Bytes()
+
+	private, err := ReadEncryptedPrivateKeyPEM(bytes.NewReader(pem), "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if private.V.Cmp(k.Private.V) != 0 || private.U.Cmp(k.Private.U) != 0 {
+		t.Errorf("got v=%d u=%d, want v=%d u=%d", private.V, private.U, k.Private.V, k.Private.U)
+	}
+
+	if _, err := ReadEncryptedPrivateKeyPEM(bytes.NewReader(pem), "hunter3"); !errors.Is(err, ErrIncorrectPassphrase) {
+		t.Errorf("err = %v, want %v", err, ErrIncorrectPassphrase)
+	}
+
+	// an encrypted key is not a plain private key
+	if _, err := ReadPrivateKeyPEM(bytes.NewReader(pem)); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("err = %v, want %v", err, ErrInvalidEncoding)
+	}
+}
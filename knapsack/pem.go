@@ -0,0 +1,230 @@
+package knapsack
+
+import (
+	"encoding/pem"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+)
+
+const (
+	pemPublicKey  = "KNAPSACK PUBLIC KEY"
+	pemPrivateKey = "KNAPSACK PRIVATE KEY"
+
+	// pemBits and pemChecksum are the PEM headers written before the key.
+	// pemBlockSize, in bytes, was written instead of pemBits by the legacyVersion.
+	// pemScheme names the Cryptosystem of keys of any scheme but Merkle–Hellman, whose keys predate it.
+	pemBits      = "Bits"
+	pemBlockSize = "Block-Size"
+	pemChecksum  = "Checksum"
+	pemScheme    = "Scheme"
+)
+
+// checksum returns the CRC-32 of data as hex.
+func checksum(data []byte) string {
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE(data))
+}
+
+// writePEM armors data (a binary encoding) as a PEM block of typ.
+func writePEM(w io.Writer, typ string, bits int, data []byte) error {
+	return writeSchemePEM(w, typ, SchemeMerkleHellman, bits, data)
+}
+
+// writeSchemePEM is writePEM for a key of scheme, recorded in a Scheme header unless it is Merkle–Hellman.
+func writeSchemePEM(w io.Writer, typ, scheme string, bits int, data []byte) error {
+	headers := map[string]string{
+		pemBits:     strconv.Itoa(bits),
+		pemChecksum: checksum(data),
+	}
+	if scheme != SchemeMerkleHellman {
+		headers[pemScheme] = scheme
+	}
+
+	return pem.Encode(w, &pem.Block{Type: typ, Headers: headers, Bytes: data})
+}
+
+// pemCryptosystem returns an empty Cryptosystem of the scheme named by block's Scheme header, Merkle–Hellman without one.
+func pemCryptosystem(block *pem.Block) (Cryptosystem, error) {
+	scheme, ok := block.Headers[pemScheme]
+	if !ok {
+		scheme = SchemeMerkleHellman
+	}
+
+	c, err := NewCryptosystem(scheme)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+
+	return c, nil
+}
+
+// checkPEMMerkleHellman checks block holds a Merkle–Hellman key, for the readers that only know that scheme.
+func checkPEMMerkleHellman(block *pem.Block) error {
+	if scheme, ok := block.Headers[pemScheme]; ok && scheme != SchemeMerkleHellman {
+		return fmt.Errorf("%w: %s key, not %s, read it as a Cryptosystem", ErrInvalidEncoding, scheme, SchemeMerkleHellman)
+	}
+
+	return nil
+}
+
+// readPEM reads the first PEM block of typ from r, checking its checksum.
+// Blocks of other types are skipped, so both keys can share a file.
+func readPEM(r io.Reader, typ string) (*pem.Block, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var block *pem.Block
+	for {
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%w: no %q PEM block found", ErrInvalidEncoding, typ)
+		}
+		if block.Type == typ {
+			break
+		}
+	}
+
+	if sum := checksum(block.Bytes); block.Headers[pemChecksum] != sum {
+		return nil, fmt.Errorf("%w: checksum %q does not match %q", ErrInvalidEncoding, block.Headers[pemChecksum], sum)
+	}
+
+	return block, nil
+}
+
+// checkPEMBits checks the Bits (or legacy Block-Size) header matches the decoded key.
+func checkPEMBits(block *pem.Block, bits int) error {
+	header, want := pemBits, strconv.Itoa(bits)
+	if _, ok := block.Headers[pemBits]; !ok && bits%8 == 0 {
+		header, want = pemBlockSize, strconv.Itoa(bits/8)
+	}
+
+	if block.Headers[header] != want {
+		return fmt.Errorf("%w: %s header %q does not match %s", ErrInvalidEncoding, header, block.Headers[header], want)
+	}
+
+	return nil
+}
+
+// WritePublicKeyPEM writes public to w as a "KNAPSACK PUBLIC KEY" PEM block.
+func WritePublicKeyPEM(w io.Writer, public PublicKey) error {
+	data, err := public.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return writePEM(w, pemPublicKey, public.Bits(), data)
+}
+
+// ReadPublicKeyPEM reads a PublicKey written by WritePublicKeyPEM.
+func ReadPublicKeyPEM(r io.Reader) (PublicKey, error) {
+	block, err := readPEM(r, pemPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPEMMerkleHellman(block); err != nil {
+		return nil, err
+	}
+
+	var public PublicKey
+	if err := public.UnmarshalBinary(block.Bytes); err != nil {
+		return nil, err
+	}
+
+	if err := checkPEMBits(block, public.Bits()); err != nil {
+		return nil, err
+	}
+
+	return public, nil
+}
+
+// WritePrivateKeyPEM writes private to w as a "KNAPSACK PRIVATE KEY" PEM block.
+func WritePrivateKeyPEM(w io.Writer, private *PrivateKey) error {
+	data, err := private.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return writePEM(w, pemPrivateKey, private.Bits(), data)
+}
+
+// ReadPrivateKeyPEM reads a PrivateKey written by WritePrivateKeyPEM.
+func ReadPrivateKeyPEM(r io.Reader) (*PrivateKey, error) {
+	block, err := readPEM(r, pemPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPEMMerkleHellman(block); err != nil {
+		return nil, err
+	}
+
+	private := new(PrivateKey)
+	if err := private.UnmarshalBinary(block.Bytes); err != nil {
+		return nil, err
+	}
+
+	if err := checkPEMBits(block, private.Bits()); err != nil {
+		return nil, err
+	}
+
+	return private, nil
+}
+
+// WriteCryptosystemPublicKeyPEM writes the public key of c to w as a "KNAPSACK PUBLIC KEY" PEM block.
+// The Scheme header is only written for schemes other than Merkle–Hellman, whose keys match WritePublicKeyPEM's.
+func WriteCryptosystemPublicKeyPEM(w io.Writer, c Cryptosystem) error {
+	data, err := c.MarshalPublicKey()
+	if err != nil {
+		return err
+	}
+
+	return writeSchemePEM(w, pemPublicKey, c.Scheme(), c.Bits(), data)
+}
+
+// ReadCryptosystemPublicKeyPEM reads a public key written by WriteCryptosystemPublicKeyPEM or WritePublicKeyPEM,
+// as a Cryptosystem of the scheme in its Scheme header.
+func ReadCryptosystemPublicKeyPEM(r io.Reader) (Cryptosystem, error) {
+	return readCryptosystemPEM(r, pemPublicKey, Cryptosystem.UnmarshalPublicKey)
+}
+
+// WriteCryptosystemPrivateKeyPEM writes the key pair c to w as a "KNAPSACK PRIVATE KEY" PEM block.
+// The Scheme header is only written for schemes other than Merkle–Hellman, whose keys match WritePrivateKeyPEM's.
+func WriteCryptosystemPrivateKeyPEM(w io.Writer, c Cryptosystem) error {
+	data, err := c.MarshalPrivateKey()
+	if err != nil {
+		return err
+	}
+
+	return writeSchemePEM(w, pemPrivateKey, c.Scheme(), c.Bits(), data)
+}
+
+// ReadCryptosystemPrivateKeyPEM reads a key pair written by WriteCryptosystemPrivateKeyPEM or WritePrivateKeyPEM,
+// as a Cryptosystem of the scheme in its Scheme header, and checks that it's valid.
+func ReadCryptosystemPrivateKeyPEM(r io.Reader) (Cryptosystem, error) {
+	return readCryptosystemPEM(r, pemPrivateKey, Cryptosystem.UnmarshalPrivateKey)
+}
+
+// readCryptosystemPEM reads the first PEM block of typ from r into a Cryptosystem with unmarshal.
+func readCryptosystemPEM(r io.Reader, typ string, unmarshal func(Cryptosystem, []byte) error) (Cryptosystem, error) {
+	block, err := readPEM(r, typ)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := pemCryptosystem(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := unmarshal(c, block.Bytes); err != nil {
+		return nil, err
+	}
+
+	if err := checkPEMBits(block, c.Bits()); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,152 @@
+package knapsack
+
+import (
+	"bytes"
+	"errors"
+	mathRand "math/rand/v2"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPEM(t *testing.T) {
+	t.Run("random", func(t *testing.T) {
+		for range 20 {
+			r := (mathRand.Int() % 8) + 1
+
+			k, err := NewKnapsack(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf := new(bytes.Buffer)
+			if err := WritePublicKeyPEM(buf, k.Public); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(buf.String(), "-----BEGIN KNAPSACK PUBLIC KEY-----") {
+				t.Errorf("missing PEM header:\n%s", buf)
+			}
+
+			public, err := ReadPublicKeyPEM(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf.Reset()
+			if err := WritePrivateKeyPEM(buf, k.Private); err != nil {
+				t.Fatal(err)
+			}
+
+			private, err := ReadPrivateKeyPEM(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cipher := public.Encrypt(NewPlaintext(public.BlockSize(), []byte("Hello World!")))
+
+			plain, err := private.Decrypt(cipher)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := FromPlaintextBits(private.Bits(), plain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != "Hello World!" {
+				t.Errorf("got %q, want %q", got, "Hello World!")
+			}
+		}
+	})
+}
+
+func TestPEMFixtures(t *testing.T) {
+	// testdata/v1 holds keys written before the headers recorded bits, testdata/v2 before keys had a Perm
+	for _, dir := range []string{"testdata", "testdata/v2", "testdata/v1"} {
+		t.Run(dir, func(t *testing.T) {
+			f, err := os.Open(dir + "/public.pem")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			public, err := ReadPublicKeyPEM(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, err = os.Open(dir + "/private.pem")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			private, err := ReadPrivateKeyPEM(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if private.V.Int64() != 13 || private.U.Int64() != 672 || private.Bits() != 8 {
+				t.Errorf("got v=%d u=%d bits=%d, want v=13 u=672 bits=8", private.V, private.U, private.Bits())
+			}
+
+			want := NewPublicKey(private, private.Set)
+			if BigIntsToStr(public) != BigIntsToStr(want) {
+				t.Errorf("got %s, want %s", BigIntsToStr(public), BigIntsToStr(want))
+			}
+		})
+	}
+}
+
+func TestPEMInvalid(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/public.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy, err := os.ReadFile("testdata/v1/public.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		pem  string
+	}{
+		{name: "empty", pem: ""},
+		{name: "wrong type", pem: strings.ReplaceAll(string(fixture), "PUBLIC", "PRIVATE")},
+		{name: "wrong checksum", pem: strings.Replace(string(fixture), "Checksum: e", "Checksum: f", 1)},
+		{name: "wrong bits", pem: strings.Replace(string(fixture), "Bits: 8", "Bits: 9", 1)},
+		{name: "wrong block size", pem: strings.Replace(string(legacy), "Block-Size: 1", "Block-Size: 2", 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadPublicKeyPEM(strings.NewReader(tt.pem)); !errors.Is(err, ErrInvalidEncoding) {
+				t.Errorf("err = %v, want %v", err, ErrInvalidEncoding)
+			}
+		})
+	}
+}
+
+func TestPEMSharedFile(t *testing.T) {
+	k, err := NewKnapsack(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WritePublicKeyPEM(buf, k.Public); err != nil {
+		t.Fatal(err)
+	}
+	if err := WritePrivateKeyPEM(buf, k.Private); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadPublicKeyPEM(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Error(err)
+	}
+	if _, err := ReadPrivateKeyPEM(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Error(err)
+	}
+}
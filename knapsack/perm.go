@@ -0,0 +1,108 @@
+package knapsack
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+)
+
+// ErrInvalidPermutation is returned when a PrivateKey's Perm isn't a permutation of its Set's indexes.
+var ErrInvalidPermutation = errors.New("invalid permutation")
+
+// randomPerm returns a uniformly random permutation of [0, n) using a Fisher–Yates shuffle.
+func randomPerm(random io.Reader, n int) ([]int, error) {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+
+	for i := n - 1; i > 0; i-- {
+		j, err := rand.Int(random, big.NewInt(int64(i+1)))
+		if err != nil {
+			return nil, err
+		}
+
+		perm[i], perm[j.Int64()] = perm[j.Int64()], perm[i]
+	}
+
+	return perm, nil
+}
+
+// validatePerm checks that perm is nil, or holds every index of [0, n) once.
+func validatePerm(perm []int, n int) error {
+	if perm == nil {
+		return nil
+	}
+
+	if len(perm) != n {
+		return fmt.Errorf("%w: %d indexes for %d values", ErrInvalidPermutation, len(perm), n)
+	}
+
+	seen := make([]bool, n)
+	for i, p := range perm {
+		if p < 0 || p >= n || seen[p] {
+			return fmt.Errorf("%w: Perm[%d] = %d is out of range or repeated", ErrInvalidPermutation, i, p)
+		}
+		seen[p] = true
+	}
+
+	return nil
+}
+
+// permute returns s reordered so that the i-th value is s[perm[i]], or s if perm is nil.
+func permute(s []*big.Int, perm []int) []*big.Int {
+	if perm == nil {
+		return s
+	}
+
+	out := make([]*big.Int, len(s))
+	for i, p := range perm {
+		out[i] = s[p]
+	}
+
+	return out
+}
+
+// unpermuteBits moves the bit solved against Set[perm[i]] to the position that selects Public[i],
+// undoing the permutation NewPublicKey applied. Bits are counted from the most significant of n.
+func unpermuteBits(plain Plaintext, perm []int, n int) Plaintext {
+	if perm == nil {
+		return plain
+	}
+
+	out := make(Plaintext, len(plain))
+	for b, block := range plain {
+		out[b] = new(big.Int)
+		for i, p := range perm {
+			out[b].SetBit(out[b], n-1-i, block.Bit(n-1-p))
+		}
+	}
+
+	return out
+}
+
+// sortedPerm sorts a copy of s ascending, returning it with the permutation
+// that maps it back to s's order, so permute(sorted, perm) equals s.
+func sortedPerm(s Set) (Set, []int) {
+	perm := make([]int, len(s))
+	for i := range perm {
+		perm[i] = i
+	}
+
+	// perm[k] is the index of s that ends up k-th
+	slices.SortStableFunc(perm, func(a, b int) int {
+		return s[a].Cmp(s[b])
+	})
+
+	sorted := make(Set, len(s))
+	inverse := make([]int, len(s))
+	for k, i := range perm {
+		sorted[k] = s[i]
+		inverse[i] = k
+	}
+
+	return sorted, inverse
+}
@@ -0,0 +1,91 @@
+package knapsack
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	mathRand "math/rand/v2"
+	"testing"
+)
+
+func TestPerm(t *testing.T) {
+	t.Run("random", func(t *testing.T) {
+		for range 50 {
+			n := mathRand.IntN(100) + 1
+
+			k, err := NewKnapsackBits(n, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := validatePerm(k.Private.Perm, n); err != nil || k.Private.Perm == nil {
+				t.Fatalf("Perm = %v: %v", k.Private.Perm, err)
+			}
+
+			data := []byte("Hello World!")
+			plain, err := k.Decrypt(k.Encrypt(k.NewPlaintext(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := k.FromPlaintext(plain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(data) {
+				t.Errorf("perm %v: got %q, want %q", k.Private.Perm, got, data)
+			}
+		}
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		private := &PrivateKey{V: big.NewInt(13), U: big.NewInt(672), Perm: []int{7, 0, 6, 1, 5, 2, 4, 3}}
+		k, err := NewKnapsackCustom(1, private, bigInts(3, 5, 9, 18, 38, 75, 155, 310))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 13*Set[Perm[i]] % 672
+		if got, want := BigIntsToStr(k.Public), "670, 39, 671, 65, 303, 117, 494, 234"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+
+		// the most significant bit selects Public[0], built from Set[7]
+		cipher := k.Encrypt(Plaintext{big.NewInt(0b10000001)})
+		if got, want := cipher[0].Int64(), int64(670+234); got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+
+		plain, err := k.Decrypt(cipher)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain[0].Int64() != 0b10000001 {
+			t.Errorf("got %b, want %b", plain[0], 0b10000001)
+		}
+	})
+
+	t.Run("sorted perm", func(t *testing.T) {
+		s := Set(bigInts(9, 3, 310, 5))
+		sorted, perm := sortedPerm(s)
+
+		if BigIntsToStr(sorted) != "3, 5, 9, 310" || fmt.Sprint(perm) != "[2 0 3 1]" {
+			t.Errorf("got %s and %v", BigIntsToStr(sorted), perm)
+		}
+		if BigIntsToStr(permute(sorted, perm)) != BigIntsToStr(s) {
+			t.Errorf("permute(sorted, perm) = %s, want %s", BigIntsToStr(permute(sorted, perm)), BigIntsToStr(s))
+		}
+	})
+}
+
+func TestPermInvalid(t *testing.T) {
+	set := bigInts(3, 5, 9, 18)
+
+	for _, perm := range [][]int{{0, 1, 2}, {0, 1, 2, 2}, {0, 1, 2, 4}, {-1, 0, 1, 2}} {
+		t.Run(fmt.Sprint(perm), func(t *testing.T) {
+			private := &PrivateKey{Set: set, V: big.NewInt(13), U: big.NewInt(672), Perm: perm}
+			if err := private.Validate(); !errors.Is(err, ErrInvalidPermutation) {
+				t.Errorf("err = %v, want %v", err, ErrInvalidPermutation)
+			}
+		})
+	}
+}
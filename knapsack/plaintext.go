@@ -0,0 +1,188 @@
+package knapsack
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+type Plaintext []*big.Int
+
+// ErrInvalidPadding is returned when a Plaintext's last block is not padded correctly.
+var ErrInvalidPadding = errors.New("invalid padding")
+
+// blockPacker packs a stream of bits into blocks of n bits, most significant bit first.
+type blockPacker struct {
+	n    int
+	acc  *big.Int // bits that don't fill a block yet
+	bits int      // # of bits in acc
+}
+
+func newBlockPacker(n int) *blockPacker {
+	return &blockPacker{n: n, acc: new(big.Int)}
+}
+
+// writeBytes appends every bit of data.
+func (p *blockPacker) writeBytes(data []byte) {
+	for _, b := range data {
+		p.acc.Lsh(p.acc, 8)
+		p.acc.Or(p.acc, big.NewInt(int64(b)))
+		p.bits += 8
+	}
+}
+
+// block removes the next full block, or returns nil if there isn't one.
+func (p *blockPacker) block() *big.Int {
+	if p.bits < p.n {
+		return nil
+	}
+
+	rest := uint(p.bits - p.n)
+	b := new(big.Int).Rsh(p.acc, rest)
+	p.acc.Sub(p.acc, new(big.Int).Lsh(b, rest))
+	p.bits -= p.n
+
+	return b
+}
+
+// pad appends a 1 bit followed by 0's up to the end of the block, and returns that last block.
+// Every full block must have been removed first.
+func (p *blockPacker) pad() *big.Int {
+	p.acc.Lsh(p.acc, 1)
+	p.acc.SetBit(p.acc, 0, 1)
+	p.bits++
+
+	b := new(big.Int).Lsh(p.acc, uint(p.n-p.bits))
+	p.acc.SetInt64(0)
+	p.bits = 0
+
+	return b
+}
+
+// blockUnpacker unpacks blocks of n bits back into bytes, the inverse of blockPacker.
+type blockUnpacker struct {
+	n    int
+	acc  *big.Int // bits that don't fill a byte yet
+	bits int      // # of bits in acc
+}
+
+func newBlockUnpacker(n int) *blockUnpacker {
+	return &blockUnpacker{n: n, acc: new(big.Int)}
+}
+
+// writeBlock appends the n bits of b.
+func (u *blockUnpacker) writeBlock(b *big.Int) error {
+	if b.Sign() < 0 || b.BitLen() > u.n {
+		return fmt.Errorf("block does not fit in %d bits", u.n)
+	}
+
+	u.acc.Lsh(u.acc, uint(u.n))
+	u.acc.Or(u.acc, b)
+	u.bits += u.n
+
+	return nil
+}
+
+// writeLast appends the bits of the last block b, without its padding.
+// It fails if the padding is missing, or doesn't leave whole bytes.
+func (u *blockUnpacker) writeLast(b *big.Int) error {
+	if b.Sign() <= 0 || b.BitLen() > u.n {
+		return ErrInvalidPadding
+	}
+
+	// remove the padded 0's, then the 1
+	pad := b.TrailingZeroBits() + 1
+	u.acc.Lsh(u.acc, uint(u.n)-pad)
+	u.acc.Or(u.acc, new(big.Int).Rsh(b, pad))
+	u.bits += u.n - int(pad)
+
+	if u.bits%8 != 0 {
+		return ErrInvalidPadding
+	}
+
+	return nil
+}
+
+// bytes removes every whole byte.
+func (u *blockUnpacker) bytes() []byte {
+	rest := uint(u.bits % 8)
+	b := new(big.Int).Rsh(u.acc, rest)
+	u.acc.Sub(u.acc, new(big.Int).Lsh(b, rest))
+
+	data := b.FillBytes(make([]byte, u.bits/8))
+	u.bits = int(rest)
+
+	return data
+}
+
+// NewPlaintextBits splits data into blocks of n bits, n doesn't need to be a multiple of 8.
+// data is always padded with a 1 bit followed by 0's up to the end of the last block (ISO/IEC 9797-1 method 2),
+// so it can be recovered exactly by FromPlaintextBits.
+// It panics if n < 1.
+func NewPlaintextBits(n int, data []byte) Plaintext {
+	if err := validateBits(n); err != nil {
+		panic("knapsack: NewPlaintextBits: " + err.Error())
+	}
+
+	// the padding always needs at least 1 bit
+	plain := make([]*big.Int, 0, len(data)*8/n+1)
+
+	p := newBlockPacker(n)
+	for i := range data {
+		p.writeBytes(data[i : i+1])
+
+		for b := p.block(); b != nil; b = p.block() {
+			plain = append(plain, b)
+		}
+	}
+
+	return append(plain, p.pad())
+}
+
+// FromPlaintextBits joins the n bit blocks of plain back into data and removes its padding.
+func FromPlaintextBits(n int, plain Plaintext) ([]byte, error) {
+	if err := validateBits(n); err != nil {
+		return nil, err
+	}
+	if len(plain) == 0 {
+		return nil, ErrInvalidPadding
+	}
+
+	data := make([]byte, 0, len(plain)*n/8)
+
+	u := newBlockUnpacker(n)
+	for i, b := range plain[:len(plain)-1] {
+		if err := u.writeBlock(b); err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		data = append(data, u.bytes()...)
+	}
+
+	if err := u.writeLast(plain[len(plain)-1]); err != nil {
+		return nil, err
+	}
+
+	return append(data, u.bytes()...), nil
+}
+
+// NewPlaintext splits data into blocks of blockSize bytes.
+// data is always padded with 0x80 followed by 0's up to the end of the last block (ISO/IEC 7816-4),
+// so it can be recovered exactly by FromPlaintext.
+// It panics if blockSize < 1.
+func NewPlaintext(blockSize int, data []byte) Plaintext {
+	// a 1 bit then 0's is 0x80 then 0's when the blocks are whole bytes
+	return NewPlaintextBits(8*blockSize, data)
+}
+
+// FromPlaintext joins the blocks of plain back into data and removes its padding.
+func FromPlaintext(blockSize int, plain Plaintext) ([]byte, error) {
+	return FromPlaintextBits(8*blockSize, plain)
+}
+
+func (k *Knapsack) NewPlaintext(data []byte) Plaintext {
+	return NewPlaintextBits(k.Bits(), data)
+}
+
+func (k *Knapsack) FromPlaintext(plain Plaintext) ([]byte, error) {
+	return FromPlaintextBits(k.Bits(), plain)
+}
@@ -0,0 +1,14 @@
+package knapsack
+
+import (
+	"crypto/sha256"
+	"io"
+	mathRand "math/rand/v2"
+)
+
+// NewSeededRand returns a deterministic random bit generator keyed by seed, for Options.Rand and the ...WithRand functions.
+// The same seed always produces the same stream, so key generation can be reproduced exactly.
+// It is ChaCha8 keyed with SHA-256(seed): only as secret as seed, so use crypto/rand for keys that must stay private.
+func NewSeededRand(seed []byte) io.Reader {
+	return mathRand.NewChaCha8(sha256.Sum256(seed))
+}
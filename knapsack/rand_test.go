@@ -0,0 +1,69 @@
+package knapsack
+
+import (
+	"testing"
+)
+
+func TestNewSeededRand(t *testing.T) {
+	t.Run("golden", func(t *testing.T) {
+		// changing how keys are derived from a seed breaks every "keygen -seed" user
+		k, err := NewKnapsackWithOptions(1, Options{Rand: NewSeededRand([]byte("knapsack"))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := BigIntsToStr(k.Private.Set), "7, 9, 24, 48, 95, 192, 379, 763"; got != want {
+			t.Errorf("set = %s, want %s", got, want)
+		}
+		if k.Private.V.Int64() != 1699 || k.Private.U.Int64() != 2427 {
+			t.Errorf("got v=%d u=%d, want v=1699 u=2427", k.Private.V, k.Private.U)
+		}
+	})
+
+	t.Run("with rand", func(t *testing.T) {
+		s1, err := RandomSetBitsWithRand(NewSeededRand([]byte("seed")), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s2, err := RandomSetWithRand(NewSeededRand([]byte("seed")), 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the first 32 values are drawn the same way
+		if BigIntsToStr(s1[:32]) != BigIntsToStr(s2) {
+			t.Errorf("got %s, want %s", BigIntsToStr(s2), BigIntsToStr(s1[:32]))
+		}
+
+		p1, err := RandomPrivateKeyWithRand(NewSeededRand([]byte("seed")), s1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p2, err := RandomPrivateKeyWithRand(NewSeededRand([]byte("seed")), s1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p1.V.Cmp(p2.V) != 0 || p1.U.Cmp(p2.U) != 0 {
+			t.Errorf("same seed gave v=%d u=%d and v=%d u=%d", p1.V, p1.U, p2.V, p2.U)
+		}
+	})
+
+	t.Run("different seeds", func(t *testing.T) {
+		s1, err := RandomSetBitsWithRand(NewSeededRand([]byte("a")), 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s2, err := RandomSetBitsWithRand(NewSeededRand([]byte("b")), 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if BigIntsToStr(s1) == BigIntsToStr(s2) {
+			t.Errorf("different seeds gave the same set %s", BigIntsToStr(s1))
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package knapsack
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+)
+
+// SetGenerator generates the superincreasing Set of a random PrivateKey.
+// Pass one in Options.Set to control the parameters of NewKnapsackWithOptions and NewKnapsackBits.
+type SetGenerator interface {
+	// GenerateSet returns a superincreasing Set of n values, reading its randomness from random.
+	GenerateSet(random io.Reader, n int) (Set, error)
+}
+
+// randomRange generates a big.Int between [lo, hi].
+func randomRange(random io.Reader, lo, hi *big.Int) (*big.Int, error) {
+	width := new(big.Int).Sub(hi, lo)
+	width.Add(width, big.NewInt(1))
+
+	i, err := rand.Int(random, width)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.Add(i, lo), nil
+}
+
+// GapGenerator makes each value the sum of the previous values plus a random gap between [Min, Max].
+// The first value is just the gap. The default generator is GapGenerator{Min: 2, Max: sMax + 1},
+// which gives dense sets of small values.
+type GapGenerator struct {
+	Min *big.Int
+	Max *big.Int
+}
+
+func (g GapGenerator) GenerateSet(random io.Reader, n int) (Set, error) {
+	if g.Min == nil || g.Max == nil || g.Min.Sign() <= 0 || g.Max.Cmp(g.Min) == -1 {
+		return nil, fmt.Errorf("gap range [%v, %v] must satisfy 0 < Min <= Max", g.Min, g.Max)
+	}
+
+	s := make(Set, n)
+	sum := big.NewInt(0)
+
+	// each step, increase by sum + [Min, Max]
+	for i := range s {
+		r, err := randomRange(random, g.Min, g.Max)
+		if err != nil {
+			return nil, err
+		}
+
+		s[i] = r.Add(r, sum)
+		sum.Add(sum, s[i])
+	}
+
+	return s, nil
+}
+
+// BitLengthGenerator picks Set[i] uniformly between the sum of the previous values and 2^(Bits+i),
+// so each value has at most Bits+i bits and the last has about Bits+n.
+type BitLengthGenerator struct {
+	Bits int
+}
+
+func (g BitLengthGenerator) GenerateSet(random io.Reader, n int) (Set, error) {
+	if g.Bits < 1 {
+		return nil, fmt.Errorf("bits must be >= 1")
+	}
+
+	s := make(Set, n)
+	sum := big.NewInt(0)
+
+	for i := range s {
+		// the previous values sum to at most 2^(Bits+i) - 2^Bits, so the range is never empty
+		lo := new(big.Int).Add(sum, big.NewInt(1))
+		hi := new(big.Int).Lsh(big.NewInt(1), uint(g.Bits+i))
+		hi.Sub(hi, big.NewInt(1))
+
+		si, err := randomRange(random, lo, hi)
+		if err != nil {
+			return nil, err
+		}
+
+		s[i] = si
+		sum.Add(sum, si)
+	}
+
+	return s, nil
+}
+
+// ClassicGenerator follows Merkle and Hellman's original paper,
+// picking Set[i] uniformly between [(2^i - 1)*2^n + 1, 2^i*2^n] (counting i from 0),
+// so every value is larger than the sum of the previous values and the last has about 2n bits.
+type ClassicGenerator struct{}
+
+func (ClassicGenerator) GenerateSet(random io.Reader, n int) (Set, error) {
+	s := make(Set, n)
+
+	for i := range s {
+		hi := new(big.Int).Lsh(big.NewInt(1), uint(n+i))
+		lo := new(big.Int).Sub(hi, new(big.Int).Lsh(big.NewInt(1), uint(n)))
+		lo.Add(lo, big.NewInt(1))
+
+		si, err := randomRange(random, lo, hi)
+		if err != nil {
+			return nil, err
+		}
+
+		s[i] = si
+	}
+
+	return s, nil
+}
+
+// DensityGenerator aims for a PublicKey with density n / log2(max Public[i]) close to Density.
+// The public values are reduced mod U, which is a few bits larger than the last Set value,
+// so it picks a BitLengthGenerator whose last value has about n/Density bits.
+// A superincreasing Set's last value has at least n bits, so Density must be below n/(n+1).
+type DensityGenerator struct {
+	Density float64
+}
+
+func (g DensityGenerator) GenerateSet(random io.Reader, n int) (Set, error) {
+	if g.Density <= 0 || math.IsNaN(g.Density) || math.IsInf(g.Density, 0) {
+		return nil, fmt.Errorf("density %v must be > 0", g.Density)
+	}
+
+	// the last value has about Bits+n bits
+	bits := int(math.Round(float64(n)/g.Density)) - n
+	if bits < 1 {
+		return nil, fmt.Errorf("density %v is too high for %d values, must be below %v", g.Density, n, float64(n)/float64(n+1))
+	}
+
+	return BitLengthGenerator{Bits: bits}.GenerateSet(random, n)
+}
@@ -0,0 +1,129 @@
+package knapsack
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestSetGenerator(t *testing.T) {
+	tests := []struct {
+		name string
+		gen  SetGenerator
+	}{
+		{name: "gap", gen: GapGenerator{Min: big.NewInt(1), Max: big.NewInt(1000)}},
+		{name: "fixed gap", gen: GapGenerator{Min: big.NewInt(5), Max: big.NewInt(5)}},
+		{name: "bit length", gen: BitLengthGenerator{Bits: 20}},
+		{name: "classic", gen: ClassicGenerator{}},
+		{name: "density", gen: DensityGenerator{Density: 0.5}},
+	}
+
+	for _, tt := range tests {
+		for _, n := range []int{1, 13, 64, 100} {
+			t.Run(fmt.Sprintf("%s/%d", tt.name, n), func(t *testing.T) {
+				k, err := NewKnapsackBits(n, Options{Rand: NewSeededRand([]byte(tt.name)), Set: tt.gen})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err := k.Validate(); err != nil {
+					t.Fatal(err)
+				}
+
+				data := []byte("Hello World!")
+				plain, err := k.Decrypt(k.Encrypt(k.NewPlaintext(data)))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := k.FromPlaintext(plain)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != string(data) {
+					t.Errorf("got %q, want %q", got, data)
+				}
+			})
+		}
+	}
+}
+
+func TestSetGeneratorRanges(t *testing.T) {
+	random := NewSeededRand([]byte("ranges"))
+
+	t.Run("fixed gap", func(t *testing.T) {
+		s, err := GapGenerator{Min: big.NewInt(5), Max: big.NewInt(5)}.GenerateSet(random, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := BigIntsToStr(s), "5, 10, 20, 40, 80"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("bit length", func(t *testing.T) {
+		s, err := BitLengthGenerator{Bits: 10}.GenerateSet(random, 50)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, si := range s {
+			if si.BitLen() > 10+i {
+				t.Errorf("Set[%d] has %d bits, want at most %d", i, si.BitLen(), 10+i)
+			}
+		}
+	})
+
+	t.Run("classic", func(t *testing.T) {
+		n := 40
+		s, err := ClassicGenerator{}.GenerateSet(random, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, si := range s {
+			// (2^i - 1)*2^n < Set[i] <= 2^i*2^n
+			hi := new(big.Int).Lsh(big.NewInt(1), uint(n+i))
+			lo := new(big.Int).Sub(hi, new(big.Int).Lsh(big.NewInt(1), uint(n)))
+			if si.Cmp(lo) != 1 || si.Cmp(hi) == 1 {
+				t.Errorf("Set[%d] = %v, want between (%v, %v]", i, si, lo, hi)
+			}
+		}
+	})
+
+	t.Run("density", func(t *testing.T) {
+		for _, want := range []float64{0.2, 0.5, 0.9} {
+			k, err := NewKnapsackBits(128, Options{Rand: random, Set: DensityGenerator{Density: want}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := Analyze(k.Public).Density; got < want-0.05 || got > want+0.05 {
+				t.Errorf("density = %v, want about %v", got, want)
+			}
+		}
+	})
+}
+
+func TestSetGeneratorInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		gen  SetGenerator
+	}{
+		{name: "missing gap", gen: GapGenerator{}},
+		{name: "zero gap", gen: GapGenerator{Min: big.NewInt(0), Max: big.NewInt(5)}},
+		{name: "reversed gap", gen: GapGenerator{Min: big.NewInt(5), Max: big.NewInt(4)}},
+		{name: "zero bits", gen: BitLengthGenerator{}},
+		{name: "zero density", gen: DensityGenerator{}},
+		{name: "density too high", gen: DensityGenerator{Density: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKnapsackBits(64, Options{Set: tt.gen}); err == nil {
+				t.Error("want error")
+			}
+		})
+	}
+}
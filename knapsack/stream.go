@@ -0,0 +1,254 @@
+package knapsack
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"math/bits"
+)
+
+// writeBlock writes b to w as a uvarint length followed by b's big-endian bytes.
+func writeBlock(w io.Writer, b *big.Int) error {
+	_, err := w.Write(appendBlock(nil, b))
+	return err
+}
+
+// readBlock reads a block written by writeBlock, rejecting blocks longer than maxLen bytes.
+// io.EOF is only returned if r ends before the block starts.
+func readBlock(r *bufio.Reader, maxLen int) (*big.Int, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if l > uint64(maxLen) {
+		return nil, fmt.Errorf("block length %d is larger than %d", l, maxLen)
+	}
+
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(buf), nil
+}
+
+// maxBlockLen returns the longest a block can be in bytes, for n values that are each < limit.
+func maxBlockLen(n int, limit *big.Int) int {
+	// a block's sum is < n * limit
+	maxBits := limit.BitLen() + bits.Len(uint(n))
+
+	return (maxBits + 7) / 8
+}
+
+// maxCiphertextLen returns the longest a Ciphertext block under params can be in bytes:
+// less than the Modulus if there is one, otherwise a sum of the Values.
+func maxCiphertextLen(params PublicParams) int {
+	if params.Modulus != nil {
+		return (params.Modulus.BitLen() + 7) / 8
+	}
+
+	largest := big.NewInt(0)
+	for _, v := range params.Values {
+		if v.Cmp(largest) > 0 {
+			largest = v
+		}
+	}
+
+	return maxBlockLen(len(params.Values), new(big.Int).Add(largest, big.NewInt(1)))
+}
+
+// ReadCiphertext reads every block written by an EncryptWriter using public.
+func ReadCiphertext(public PublicKey, r io.Reader) (Ciphertext, error) {
+	br := bufio.NewReader(r)
+	maxLen := maxCiphertextLen(PublicParams{Values: public})
+
+	cipher := make(Ciphertext, 0)
+	for {
+		c, err := readBlock(br, maxLen)
+		if errors.Is(err, io.EOF) {
+			return cipher, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		cipher = append(cipher, c)
+	}
+}
+
+// blockEncrypter is the half of a key pair an EncryptWriter needs, a PublicKey or any Cryptosystem.
+type blockEncrypter interface {
+	Bits() int
+	Encrypt(plain Plaintext) Ciphertext
+}
+
+// blockDecrypter is the half of a key pair a DecryptReader needs, a *PrivateKey or any Cryptosystem.
+type blockDecrypter interface {
+	Bits() int
+	Decrypt(cipher Ciphertext) (Plaintext, error)
+}
+
+// EncryptWriter encrypts everything written to it one block at a time.
+// Close must be called to write the final, padded block.
+type EncryptWriter struct {
+	public blockEncrypter
+	w      io.Writer
+	packer *blockPacker
+	closed bool
+	err    error // an invalid key, or the first error writing to w, returned by every later call
+}
+
+// NewEncryptWriter returns an EncryptWriter that writes public's Ciphertext blocks to w.
+// If public has no bits, every Write and Close returns an error.
+func NewEncryptWriter(public PublicKey, w io.Writer) *EncryptWriter {
+	return newEncryptWriter(public, w)
+}
+
+func newEncryptWriter(public blockEncrypter, w io.Writer) *EncryptWriter {
+	e := &EncryptWriter{
+		public: public,
+		w:      w,
+		packer: newBlockPacker(public.Bits()),
+	}
+	// a block of 0 bits is never full, so Write would never return
+	e.err = validateBits(public.Bits())
+
+	return e
+}
+
+// Write encrypts every block p fills. If writing a block fails, the bytes up to and including the one that filled it
+// count as written and the error is returned by every later Write and Close.
+func (e *EncryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed EncryptWriter")
+	}
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	for i := range p {
+		e.packer.writeBytes(p[i : i+1])
+
+		// encrypt every block that is now full
+		for block := e.packer.block(); block != nil; block = e.packer.block() {
+			if err := writeBlock(e.w, e.public.Encrypt(Plaintext{block})[0]); err != nil {
+				// byte i is already packed, and partly in the block that failed
+				e.err = err
+				return i + 1, err
+			}
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close pads and writes the final block. It does not close the underlying io.Writer.
+func (e *EncryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	if e.err != nil {
+		return e.err
+	}
+
+	// the remainder is always shorter than a block, so it pads into exactly one block
+	return writeBlock(e.w, e.public.Encrypt(Plaintext{e.packer.pad()})[0])
+}
+
+// DecryptReader decrypts the blocks written by an EncryptWriter.
+type DecryptReader struct {
+	private  blockDecrypter
+	r        *bufio.Reader
+	maxLen   int
+	unpacker *blockUnpacker
+	pending  *big.Int // the last decrypted block, held back until we know if it is padded
+	blocks   uint64
+	buf      []byte
+	err      error
+}
+
+// NewDecryptReader returns a DecryptReader that reads Ciphertext blocks from r and decrypts them with private.
+func NewDecryptReader(private *PrivateKey, r io.Reader) *DecryptReader {
+	// every PublicKey value is < modulus
+	return newDecryptReader(private, maxBlockLen(private.Bits(), private.modulus()), r)
+}
+
+// newDecryptReader returns a DecryptReader rejecting Ciphertext blocks longer than maxLen bytes.
+func newDecryptReader(private blockDecrypter, maxLen int, r io.Reader) *DecryptReader {
+	return &DecryptReader{
+		private:  private,
+		r:        bufio.NewReader(r),
+		maxLen:   maxLen,
+		unpacker: newBlockUnpacker(private.Bits()),
+	}
+}
+
+// Blocks returns the number of Ciphertext blocks read so far.
+func (d *DecryptReader) Blocks() uint64 {
+	return d.blocks
+}
+
+func (d *DecryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.fill()
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+
+	return n, nil
+}
+
+// fill decrypts the next block into buf, or sets err.
+func (d *DecryptReader) fill() {
+	c, err := readBlock(d.r, d.maxLen)
+	if errors.Is(err, io.EOF) {
+		// the stream is finished, so the pending block is the padded one
+		if d.pending == nil {
+			d.err = io.ErrUnexpectedEOF
+			return
+		}
+
+		if err := d.unpacker.writeLast(d.pending); err != nil {
+			d.err = err
+			return
+		}
+
+		d.buf = d.unpacker.bytes()
+		d.pending = nil
+		d.err = io.EOF
+		return
+	}
+	if err != nil {
+		d.err = err
+		return
+	}
+	d.blocks++
+
+	plain, err := d.private.Decrypt(Ciphertext{c})
+	if err != nil {
+		d.err = err
+		return
+	}
+
+	// release the previous block now that another follows it
+	if d.pending != nil {
+		if err := d.unpacker.writeBlock(d.pending); err != nil {
+			d.err = err
+			return
+		}
+		d.buf = d.unpacker.bytes()
+	}
+	d.pending = plain[0]
+}
@@ -0,0 +1,170 @@
+package knapsack
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	mathRand "math/rand/v2"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	t.Run("random", func(t *testing.T) {
+		for range 100 {
+			// any number of bits, not just whole bytes
+			n := mathRand.IntN(130) + 1
+
+			k, err := NewKnapsackBits(n, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data := make([]byte, mathRand.IntN(100))
+			_, _ = rand.Read(data)
+
+			// write in uneven chunks
+			cipher := new(bytes.Buffer)
+			w := NewEncryptWriter(k.Public, cipher)
+			for rest := data; len(rest) > 0; {
+				c := min(mathRand.IntN(10)+1, len(rest))
+				if _, err := w.Write(rest[:c]); err != nil {
+					t.Fatal(err)
+				}
+				rest = rest[c:]
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := io.ReadAll(NewDecryptReader(k.Private, cipher))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, data) {
+				t.Errorf("got %#v, want %#v", got, data)
+			}
+		}
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		k, err := NewKnapsack(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := errors.New("disk full")
+		w := NewEncryptWriter(k.Public, errWriter{want})
+
+		// the first block fills at the 2nd byte, which counts as written
+		if n, err := w.Write([]byte("Hello")); n != 2 || !errors.Is(err, want) {
+			t.Errorf("Write() = %d, %v, want 2, %v", n, err, want)
+		}
+		if n, err := w.Write([]byte("World")); n != 0 || !errors.Is(err, want) {
+			t.Errorf("Write() after error = %d, %v, want 0, %v", n, err, want)
+		}
+		if err := w.Close(); !errors.Is(err, want) {
+			t.Errorf("Close() = %v, want %v", err, want)
+		}
+	})
+}
+
+func TestEncryptWriterNoBits(t *testing.T) {
+	cipher := new(bytes.Buffer)
+	w := NewEncryptWriter(PublicKey{}, cipher)
+
+	if n, err := w.Write([]byte("Hello")); n != 0 || err == nil {
+		t.Errorf("Write() = %d, %v, want 0 and an error", n, err)
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Close() = nil, want error")
+	}
+	if cipher.Len() != 0 {
+		t.Errorf("wrote %d bytes, want none", cipher.Len())
+	}
+}
+
+// errWriter fails every write with err.
+type errWriter struct {
+	err error
+}
+
+func (w errWriter) Write([]byte) (int, error) {
+	return 0, w.err
+}
+
+func TestDecryptReaderInvalid(t *testing.T) {
+	k, err := NewKnapsack(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cipher := new(bytes.Buffer)
+	w := NewEncryptWriter(k.Public, cipher)
+	if _, err := w.Write([]byte("Hello World!")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	full := cipher.Bytes()
+
+	tests := []struct {
+		name   string
+		cipher []byte
+	}{
+		{name: "empty", cipher: []byte{}},
+		{name: "truncated block", cipher: full[:len(full)-1]},
+		{name: "too long", cipher: []byte{0xff, 0xff, 0xff, 0xff, 0x0f}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := io.ReadAll(NewDecryptReader(k.Private, bytes.NewReader(tt.cipher)))
+			if err == nil {
+				t.Errorf("ReadAll() = %#v, want error", got)
+			}
+		})
+	}
+
+	t.Run("missing last block", func(t *testing.T) {
+		// dropping the padded block leaves a full data block as the last one
+		short := new(bytes.Buffer)
+		w := NewEncryptWriter(k.Public, short)
+		if _, err := w.Write([]byte("Hi")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(NewDecryptReader(k.Private, bytes.NewReader(short.Bytes()))); !errors.Is(err, ErrInvalidPadding) {
+			t.Errorf("err = %v, want %v", err, ErrInvalidPadding)
+		}
+	})
+}
+
+func TestReadCiphertext(t *testing.T) {
+	k, err := NewKnapsack(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("Hello World!")
+
+	buf := new(bytes.Buffer)
+	w := NewEncryptWriter(k.Public, buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadCiphertext(k.Public, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := k.Encrypt(k.NewPlaintext(data))
+	if BigIntsToStr(got) != BigIntsToStr(want) {
+		t.Errorf("got %s, want %s", BigIntsToStr(got), BigIntsToStr(want))
+	}
+}
@@ -0,0 +1,115 @@
+package knapsack
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Errors returned by PrivateKey.Validate and Knapsack.Validate.
+// They are wrapped with the values that violate them, so check them with errors.Is.
+var (
+	ErrEmptySet           = errors.New("set is empty")
+	ErrNotSuperincreasing = errors.New("set is not superincreasing")
+	ErrSetLength          = errors.New("set length does not match block size")
+	ErrModulusTooSmall    = errors.New("U is not larger than the sum of the set")
+	ErrMultiplierRange    = errors.New("V is not between 0 and U")
+	ErrNotCoprime         = errors.New("V and U are not coprime")
+	ErrKeyMismatch        = errors.New("public key does not match private key")
+)
+
+// Sum returns the sum of every value in s.
+func (s Set) Sum() *big.Int {
+	sum := big.NewInt(0)
+	for _, si := range s {
+		sum.Add(sum, si)
+	}
+
+	return sum
+}
+
+// validateSet checks that every value of s is positive and larger than the sum of the values before it.
+func validateSet(s Set) error {
+	if len(s) == 0 {
+		return ErrEmptySet
+	}
+
+	sum := big.NewInt(0)
+	for i, si := range s {
+		if si == nil || si.Sign() <= 0 {
+			return fmt.Errorf("%w: Set[%d] = %v is not positive", ErrNotSuperincreasing, i, si)
+		}
+
+		// if si <= sum
+		if si.Cmp(sum) != 1 {
+			return fmt.Errorf("%w: Set[%d] = %v is not larger than the sum of the previous values %v", ErrNotSuperincreasing, i, si, sum)
+		}
+		sum.Add(sum, si)
+	}
+
+	return nil
+}
+
+// Validate checks every condition Merkle–Hellman needs for p to decrypt correctly:
+// the Set is superincreasing, U > ΣSet, 0 < V < U, GCD(V, U) = 1, the same for every Round of the knapsack
+// it multiplies, and Perm is a permutation.
+// The first violated condition is returned, wrapping one of the Err* validation errors or ErrInvalidPermutation.
+func (p *PrivateKey) Validate() error {
+	if err := validateSet(p.Set); err != nil {
+		return err
+	}
+
+	if sum := p.Set.Sum(); p.U == nil || p.U.Cmp(sum) != 1 {
+		return fmt.Errorf("%w: U = %v, sum = %v", ErrModulusTooSmall, p.U, sum)
+	}
+
+	if p.V == nil || p.V.Sign() <= 0 || p.V.Cmp(p.U) != -1 {
+		return fmt.Errorf("%w: V = %v, U = %v", ErrMultiplierRange, p.V, p.U)
+	}
+
+	if !validGCD(p.V, p.U) {
+		return fmt.Errorf("%w: GCD(%v, %v) != 1", ErrNotCoprime, p.V, p.U)
+	}
+
+	if err := p.validateRounds(); err != nil {
+		return err
+	}
+
+	return validatePerm(p.Perm, len(p.Set))
+}
+
+// Validate checks that p has at least one value and that every value is positive,
+// which is all that can be checked without the PrivateKey it was derived from.
+func (p PublicKey) Validate() error {
+	if len(p) == 0 {
+		return fmt.Errorf("public key is empty")
+	}
+
+	for i, pi := range p {
+		if pi == nil || pi.Sign() <= 0 {
+			return fmt.Errorf("Public[%d] = %v is not positive", i, pi)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that k.Private is valid and that k.Public was derived from it.
+func (k *Knapsack) Validate() error {
+	if err := k.Private.Validate(); err != nil {
+		return err
+	}
+
+	if len(k.Public) != len(k.Private.Set) {
+		return fmt.Errorf("%w: %d public values, %d set values", ErrKeyMismatch, len(k.Public), len(k.Private.Set))
+	}
+
+	want := NewPublicKey(k.Private, k.Private.Set)
+	for i := range want {
+		if k.Public[i] == nil || k.Public[i].Cmp(want[i]) != 0 {
+			return fmt.Errorf("%w: Public[%d] = %v, want %v", ErrKeyMismatch, i, k.Public[i], want[i])
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package knapsack
+
+import (
+	"errors"
+	"math/big"
+	mathRand "math/rand/v2"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	set := bigInts(3, 5, 9, 18, 38, 75, 155, 310)
+
+	tests := []struct {
+		name    string
+		private *PrivateKey
+		want    error
+	}{
+		{name: "valid", private: &PrivateKey{Set: set, V: big.NewInt(13), U: big.NewInt(672)}},
+		{name: "empty set", private: &PrivateKey{Set: Set{}, V: big.NewInt(13), U: big.NewInt(672)}, want: ErrEmptySet},
+		{name: "not superincreasing", private: &PrivateKey{Set: bigInts(3, 5, 8), V: big.NewInt(13), U: big.NewInt(672)}, want: ErrNotSuperincreasing},
+		{name: "not positive", private: &PrivateKey{Set: bigInts(0, 5, 9), V: big.NewInt(13), U: big.NewInt(672)}, want: ErrNotSuperincreasing},
+		{name: "u equals sum", private: &PrivateKey{Set: set, V: big.NewInt(13), U: big.NewInt(613)}, want: ErrModulusTooSmall},
+		// larger than the last value, but not the sum
+		{name: "u below sum", private: &PrivateKey{Set: set, V: big.NewInt(13), U: big.NewInt(400)}, want: ErrModulusTooSmall},
+		{name: "missing u", private: &PrivateKey{Set: set, V: big.NewInt(13)}, want: ErrModulusTooSmall},
+		{name: "v zero", private: &PrivateKey{Set: set, V: big.NewInt(0), U: big.NewInt(672)}, want: ErrMultiplierRange},
+		{name: "v equals u", private: &PrivateKey{Set: set, V: big.NewInt(672), U: big.NewInt(672)}, want: ErrMultiplierRange},
+		{name: "v above u", private: &PrivateKey{Set: set, V: big.NewInt(685), U: big.NewInt(672)}, want: ErrMultiplierRange},
+		{name: "not coprime", private: &PrivateKey{Set: set, V: big.NewInt(14), U: big.NewInt(672)}, want: ErrNotCoprime},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.private.Validate(); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+
+			// the constructors enforce the same conditions
+			if len(tt.private.Set) == 8 {
+				if _, err := NewKnapsackCustom(1, tt.private, tt.private.Set); !errors.Is(err, tt.want) {
+					t.Errorf("NewKnapsackCustom() err = %v, want %v", err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateKnapsack(t *testing.T) {
+	t.Run("random", func(t *testing.T) {
+		for range 100 {
+			k, err := NewKnapsackBits(mathRand.IntN(256)+1, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := k.Validate(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	k, err := NewKnapsack(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("modified public key", func(t *testing.T) {
+		mismatch := &Knapsack{Private: k.Private, Public: append(PublicKey{}, k.Public...)}
+		mismatch.Public[3] = new(big.Int).Add(mismatch.Public[3], big.NewInt(1))
+
+		if err := mismatch.Validate(); !errors.Is(err, ErrKeyMismatch) {
+			t.Errorf("err = %v, want %v", err, ErrKeyMismatch)
+		}
+	})
+
+	t.Run("short public key", func(t *testing.T) {
+		mismatch := &Knapsack{Private: k.Private, Public: k.Public[1:]}
+
+		if err := mismatch.Validate(); !errors.Is(err, ErrKeyMismatch) {
+			t.Errorf("err = %v, want %v", err, ErrKeyMismatch)
+		}
+	})
+
+	t.Run("set length", func(t *testing.T) {
+		if _, err := NewKnapsackCustom(2, k.Private, k.Private.Set); !errors.Is(err, ErrSetLength) {
+			t.Errorf("err = %v, want %v", err, ErrSetLength)
+		}
+	})
+}
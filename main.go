@@ -1,130 +1,144 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"github.com/chronotrax/knapsack/knapsack"
-	"math/big"
+	"io"
 	"os"
-	"strconv"
-	"strings"
 )
 
+// command is a knapsack subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+var commands = []command{
+	{name: "keygen", summary: "generate a key pair", run: keygen},
+	{name: "encrypt", summary: "encrypt data with a public key", run: encrypt},
+	{name: "decrypt", summary: "decrypt data with a private key", run: decrypt},
+	{name: "attack", summary: "run Shamir's lattice attack against ciphertext", run: attack},
+	{name: "bruteforce", summary: "brute force private keys for ciphertext", run: bruteforce},
+	{name: "inspect", summary: "print a key or ciphertext file, with a density report for keys", run: inspect},
+}
+
+// errUsage is returned when a command is called incorrectly. Its usage has already been printed.
+var errUsage = errors.New("usage error")
+
 func main() {
-	var k *knapsack.Knapsack
-	var data []byte
-	maxKeys := uint64(5)
-
-	l := len(os.Args)
-
-	if l == 1 {
-		// no args given, use random cryptosystem
-		fmt.Println("using a random cryptosystem")
-		var err error
-		k, err = knapsack.NewKnapsack(1)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
 
-		data = []byte("Hello World!")
-	} else if l >= 5 {
-		// use given the crypto system
-		fmt.Println("using the given cryptosystem")
-		// read v argument
-		v, success := new(big.Int).SetString(os.Args[1], 10)
-		if !success {
-			fmt.Println("v is not an integer")
-			return
-		}
+// run runs the subcommand in args and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
 
-		// read u argument
-		u, success := new(big.Int).SetString(os.Args[2], 10)
-		if !success {
-			fmt.Println("u is not an integer")
-			return
-		}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		usage(stdout)
+		return 0
+	}
 
-		private := &knapsack.PrivateKey{
-			U: u,
-			V: v,
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
 		}
 
-		// read maxKeys argument
-		maxK, err := strconv.ParseUint(os.Args[3], 10, 64)
-		if err != nil {
-			fmt.Println("maxKeys is not a uint:", err)
-			return
-		}
-		maxKeys = maxK
-
-		// read data argument
-		for _, dStr := range strings.Split(os.Args[4], ",") {
-			s := strings.TrimSpace(dStr)
-			if len(s) == 0 {
-				continue
-			}
-
-			if len(s) != 2 {
-				fmt.Println("not a hex string (0x00)", s)
-				return
-			}
-
-			d, err := strconv.ParseUint(s, 16, 8)
-			if err != nil {
-				fmt.Println("invalid hex", s)
-				return
-			}
-			data = append(data, byte(d))
+		err := c.run(args[1:], stdin, stdout, stderr)
+		switch {
+		case err == nil, errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, errUsage):
+			return 2
+		default:
+			fmt.Fprintf(stderr, "%s: %v\n", c.name, err)
+			return 1
 		}
+	}
 
-		// read set argument
-		s := make([]*big.Int, 0)
-		for _, sStr := range strings.Split(os.Args[5], ",") {
-			si, success := new(big.Int).SetString(strings.TrimSpace(sStr), 10)
-			if !success {
-				fmt.Println("invalid s value:", err)
-				return
-			}
-			s = append(s, si)
-		}
+	fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+	usage(stderr)
+	return 2
+}
 
-		k, err = knapsack.NewKnapsackCustom(len(s)/8, private, s)
-	} else {
-		// print help
-		fmt.Println("usage: ./knapsack [v] [u] [max # of keys to brute force] [hex string to encrypt] [s1,s1,...,s8]")
-		return
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: knapsack <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `run "knapsack <command> -h" for a command's flags`)
+}
+
+// newFlagSet creates a flag.FlagSet for a command, printing its usage to stderr.
+func newFlagSet(name, args, summary string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: knapsack %s %s\n\n%s\n\nflags:\n", name, args, summary)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// parse parses args into fs, converting parse failures into errUsage.
+// No positional arguments are accepted.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
 	}
 
-	if k == nil {
-		fmt.Println("k == nil, something went wrong")
-		return
+	if fs.NArg() != 0 {
+		fmt.Fprintf(fs.Output(), "unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return errUsage
 	}
 
-	fmt.Println("block size (in bytes): ", k.BlockSize)
-	fmt.Println("block size (in bits): ", k.BlockSize*8)
-	fmt.Printf("private key: v=%d, u=%d\n", k.Private.V, k.Private.U)
-	fmt.Println("public key: ", k.Public)
-	fmt.Println("data: ", data, string(data))
-
-	fmt.Println("\nencrypting...")
-	plain := k.NewPlaintext(data)
-	cipher := k.Encrypt(plain)
-	fmt.Println("ciphertext: ", cipher)
-
-	fmt.Println("\ndecrypting...")
-	newPlain, err := k.Decrypt(cipher)
-	if err != nil {
-		fmt.Println(err)
-		return
+	return nil
+}
+
+// required prints fs's usage and returns errUsage if any of names were not set.
+func required(fs *flag.FlagSet, names ...string) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for _, name := range names {
+		if !set[name] {
+			fmt.Fprintf(fs.Output(), "missing required flag -%s\n", name)
+			fs.Usage()
+			return errUsage
+		}
 	}
-	newData := k.FromPlaintext(newPlain)
 
-	fmt.Println("decrypted data: ", newData, string(newData))
-	fmt.Println("original data: ", data, string(data))
+	return nil
+}
 
-	fmt.Println("\n\nStarting Shamir attack...")
-	knapsack.Attack(k.BlockSize, cipher, k.Public, data)
+// exclusive prints fs's usage and returns errUsage if any of names were set, since they only apply without -with.
+func exclusive(fs *flag.FlagSet, with string, names ...string) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for _, name := range names {
+		if set[name] {
+			fmt.Fprintf(fs.Output(), "flag -%s can't be combined with -%s\n", name, with)
+			fs.Usage()
+			return errUsage
+		}
+	}
 
-	fmt.Println("\n\nbrute forcing decryption...")
-	knapsack.BruteForce(k.BlockSize, cipher, k.Public, data, maxKeys)
+	return nil
 }
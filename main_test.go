@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runArgs runs the command line args with stdin and returns its exit code, stdout and stderr.
+func runArgs(stdin string, args ...string) (int, string, string) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	code := run(args, strings.NewReader(stdin), stdout, stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+// writeKeys generates a key pair of scheme in dir with keygen and returns the paths of its public and private keys.
+func writeKeys(t *testing.T, dir, scheme string, args ...string) (string, string) {
+	t.Helper()
+
+	public, private := filepath.Join(dir, scheme+".pub"), filepath.Join(dir, scheme+".key")
+	args = append([]string{"keygen", "-scheme", scheme, "-bits", "16", "-seed", scheme, "-public", public, "-private", private}, args...)
+	if code, _, stderr := runArgs("", args...); code != 0 {
+		t.Fatalf("keygen exited with %d: %s", code, stderr)
+	}
+
+	return public, private
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	public, private := writeKeys(t, dir, "merkle-hellman")
+	missing := filepath.Join(dir, "missing")
+
+	garbage := filepath.Join(dir, "garbage")
+	if err := os.WriteFile(garbage, []byte("not a key"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a ciphertext container for the attacks
+	cipher := filepath.Join(dir, "cipher")
+	if code, _, stderr := runArgs("Hello World!", "encrypt", "-key", public, "-out", cipher); code != 0 {
+		t.Fatalf("encrypt exited with %d: %s", code, stderr)
+	}
+
+	// a key with a small u to brute force
+	small, smallCipher := filepath.Join(dir, "small.pub"), filepath.Join(dir, "small.cipher")
+	code, _, stderr := runArgs("", "keygen", "-v", "13", "-u", "672", "-set", "3,5,9,18,38,75,155,310", "-public", small, "-private", filepath.Join(dir, "small.key"))
+	if code != 0 {
+		t.Fatalf("keygen exited with %d: %s", code, stderr)
+	}
+	if code, _, stderr := runArgs("Hi", "encrypt", "-key", small, "-out", smallCipher); code != 0 {
+		t.Fatalf("encrypt exited with %d: %s", code, stderr)
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		want   int
+		stdout string // a substring of stdout, if set
+		stderr string // a substring of stderr, if set
+	}{
+		{name: "no command", want: 2, stderr: "usage: knapsack <command>"},
+		{name: "help", args: []string{"help"}, want: 0, stdout: "usage: knapsack <command>"},
+		{name: "-h", args: []string{"-h"}, want: 0, stdout: "commands:"},
+		{name: "unknown command", args: []string{"sign"}, want: 2, stderr: `unknown command "sign"`},
+		{name: "command help", args: []string{"keygen", "-h"}, want: 0, stderr: "usage: knapsack keygen"},
+		{name: "unknown flag", args: []string{"encrypt", "-bogus"}, want: 2, stderr: "flag provided but not defined: -bogus"},
+
+		{name: "keygen argument", args: []string{"keygen", "extra"}, want: 2, stderr: `unexpected argument "extra"`},
+		{name: "keygen missing -u", args: []string{"keygen", "-v", "3"}, want: 2, stderr: "missing required flag -u"},
+		{name: "keygen custom -rounds", args: []string{"keygen", "-v", "3", "-u", "11", "-set", "1,2", "-rounds", "2"}, want: 2, stderr: "flag -rounds can't be combined with -set"},
+		{name: "keygen custom -seed", args: []string{"keygen", "-v", "3", "-u", "11", "-set", "1,2", "-seed", "x"}, want: 2, stderr: "flag -seed can't be combined with -set"},
+		{name: "keygen scheme -generator", args: []string{"keygen", "-scheme", "chor-rivest", "-generator", "classic"}, want: 2, stderr: "flag -generator can't be combined with -scheme"},
+		{name: "keygen classic -max-gap", args: []string{"keygen", "-generator", "classic", "-max-gap", "4"}, want: 2, stderr: "flag -max-gap can't be combined with -generator"},
+		{name: "keygen density -max-gap", args: []string{"keygen", "-generator", "density:0.5", "-max-gap", "4"}, want: 2, stderr: "flag -max-gap can't be combined with -generator"},
+		{name: "keygen unknown scheme", args: []string{"keygen", "-scheme", "rsa"}, want: 1, stderr: `keygen: unknown scheme "rsa"`},
+		{name: "keygen -max-gap", args: []string{"keygen", "-max-gap", "x"}, want: 1, stderr: `max-gap is not an integer: "x"`},
+		{name: "keygen existing", args: []string{"keygen", "-public", public, "-private", private}, want: 1, stderr: "already exists, use -force"},
+		{name: "keygen invalid key", args: []string{"keygen", "-v", "3", "-u", "2", "-set", "1,2,4,8,16,32,64,128", "-public", "-", "-private", "-"}, want: 1, stderr: "keygen: "},
+
+		{name: "encrypt no -key", args: []string{"encrypt"}, want: 2, stderr: "missing required flag -key"},
+		{name: "encrypt argument", args: []string{"encrypt", "-key", public, "extra"}, want: 2, stderr: `unexpected argument "extra"`},
+		{name: "encrypt missing key", args: []string{"encrypt", "-key", missing}, want: 1, stderr: "encrypt: "},
+		{name: "encrypt invalid key", args: []string{"encrypt", "-key", garbage}, want: 1, stderr: "encrypt: "},
+		{name: "encrypt private key", args: []string{"encrypt", "-key", private}, want: 1, stderr: "encrypt: "},
+		{name: "encrypt missing input", args: []string{"encrypt", "-key", public, "-in", missing}, want: 1, stderr: "encrypt: "},
+
+		{name: "decrypt no -key", args: []string{"decrypt"}, want: 2, stderr: "missing required flag -key"},
+		{name: "decrypt argument", args: []string{"decrypt", "-key", private, "extra"}, want: 2, stderr: `unexpected argument "extra"`},
+		{name: "decrypt missing key", args: []string{"decrypt", "-key", missing}, want: 1, stderr: "decrypt: "},
+		{name: "decrypt public key", args: []string{"decrypt", "-key", public, "-in", cipher}, want: 1, stderr: "decrypt: "},
+		{name: "decrypt invalid ciphertext", args: []string{"decrypt", "-key", private}, stdin: "not a container", want: 1, stderr: "decrypt: "},
+		{name: "decrypt missing passphrase", args: []string{"decrypt", "-key", private, "-passphrase-file", missing, "-in", cipher}, want: 1, stderr: "decrypt: "},
+
+		{name: "attack no -key", args: []string{"attack"}, want: 2, stderr: "missing required flag -key"},
+		{name: "attack argument", args: []string{"attack", "-key", public, "extra"}, want: 2, stderr: `unexpected argument "extra"`},
+		{name: "attack unknown lattice", args: []string{"attack", "-key", public, "-lattice", "bogus", "-in", cipher}, want: 1, stderr: "attack: "},
+		{name: "attack -scale", args: []string{"attack", "-key", public, "-scale", "x", "-in", cipher}, want: 1, stderr: `scale is not an integer: "x"`},
+		{name: "attack missing key", args: []string{"attack", "-key", missing, "-in", cipher}, want: 1, stderr: "attack: "},
+		{name: "attack invalid ciphertext", args: []string{"attack", "-key", public}, stdin: "not a container", want: 1, stderr: "attack: "},
+
+		{name: "bruteforce no -key", args: []string{"bruteforce"}, want: 2, stderr: "missing required flag -key"},
+		{name: "bruteforce argument", args: []string{"bruteforce", "-key", public, "extra"}, want: 2, stderr: `unexpected argument "extra"`},
+		{name: "bruteforce missing key", args: []string{"bruteforce", "-key", missing, "-in", cipher}, want: 1, stderr: "bruteforce: "},
+		{name: "bruteforce", args: []string{"bruteforce", "-key", small, "-in", smallCipher, "-max-keys", "1"}, want: 0, stdout: `recovered plaintext: "Hi"`, stderr: "using "},
+
+		{name: "inspect argument", args: []string{"inspect", "extra"}, want: 2, stderr: `unexpected argument "extra"`},
+		{name: "inspect missing file", args: []string{"inspect", "-in", missing}, want: 1, stderr: "inspect: "},
+		{name: "inspect garbage", args: []string{"inspect", "-in", garbage}, want: 1, stderr: "no PEM blocks or ciphertext container found"},
+		{name: "inspect public key", args: []string{"inspect", "-in", public}, want: 0, stdout: "public key"},
+		{name: "inspect ciphertext", args: []string{"inspect", "-in", cipher}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := runArgs(tt.stdin, tt.args...)
+			if code != tt.want {
+				t.Errorf("exit code = %d, want %d\nstderr: %s", code, tt.want, stderr)
+			}
+			if !strings.Contains(stdout, tt.stdout) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout, tt.stdout)
+			}
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr, tt.stderr)
+			}
+		})
+	}
+}
+
+func TestRunRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	passphrase := filepath.Join(dir, "passphrase")
+	if err := os.WriteFile(passphrase, []byte("correct horse battery staple\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, scheme := range []string{"merkle-hellman", "chor-rivest", "naccache-stern"} {
+		t.Run(scheme, func(t *testing.T) {
+			public, private := writeKeys(t, dir, scheme, "-passphrase-file", passphrase)
+
+			code, cipher, stderr := runArgs("Hello World!", "encrypt", "-key", public)
+			if code != 0 {
+				t.Fatalf("encrypt exited with %d: %s", code, stderr)
+			}
+
+			code, plain, stderr := runArgs(cipher, "decrypt", "-key", private, "-passphrase-file", passphrase)
+			if code != 0 {
+				t.Fatalf("decrypt exited with %d: %s", code, stderr)
+			}
+			if plain != "Hello World!" {
+				t.Errorf("decrypt = %q, want %q", plain, "Hello World!")
+			}
+
+			// an encrypted key needs its passphrase
+			if code, _, _ := runArgs(cipher, "decrypt", "-key", private); code != 1 {
+				t.Errorf("decrypt without -passphrase-file exited with %d, want 1", code)
+			}
+
+			code, stdout, stderr := runArgs("", "inspect", "-in", public)
+			if code != 0 {
+				t.Fatalf("inspect exited with %d: %s", code, stderr)
+			}
+			if !strings.Contains(stdout, "block size (in bits): ") {
+				t.Errorf("inspect = %q, want the block size", stdout)
+			}
+		})
+	}
+}
+
+func TestKeygenPartialWrite(t *testing.T) {
+	dir := t.TempDir()
+	public, private := filepath.Join(dir, "knapsack.pub"), filepath.Join(dir, "knapsack.key")
+	if err := os.WriteFile(private, []byte("existing"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "existing private key", args: []string{"-private", private}},
+		{name: "missing passphrase file", args: []string{"-private", filepath.Join(dir, "new.key"), "-passphrase-file", filepath.Join(dir, "missing")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"keygen", "-public", public}, tt.args...)
+			if code, _, stderr := runArgs("", args...); code != 1 {
+				t.Fatalf("exit code = %d, want 1\nstderr: %s", code, stderr)
+			}
+
+			// neither key is written without the other
+			for _, path := range []string{public, filepath.Join(dir, "new.key")} {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("%s exists, err = %v", filepath.Base(path), err)
+				}
+			}
+			if data, _ := os.ReadFile(private); string(data) != "existing" {
+				t.Errorf("existing private key was overwritten with %q", data)
+			}
+		})
+	}
+}
+
+func TestDecryptOutput(t *testing.T) {
+	dir := t.TempDir()
+	public, private := writeKeys(t, dir, "merkle-hellman")
+	cipher, out := filepath.Join(dir, "cipher"), filepath.Join(dir, "out")
+	if code, _, stderr := runArgs("Hello World!", "encrypt", "-key", public, "-out", cipher); code != 0 {
+		t.Fatalf("encrypt exited with %d: %s", code, stderr)
+	}
+	if err := os.WriteFile(out, []byte("existing"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(cipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the last block is checked after every other block was already decrypted
+	truncated := data[:len(data)-1]
+
+	if code, _, _ := runArgs(string(truncated), "decrypt", "-key", private, "-out", out); code != 1 {
+		t.Fatalf("decrypt of a truncated container exited with %d, want 1", code)
+	}
+	if got, _ := os.ReadFile(out); string(got) != "existing" {
+		t.Errorf("failed decrypt replaced -out with %q", got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 4 {
+		t.Errorf("temporary output left behind: %v", entries)
+	}
+
+	if code, _, stderr := runArgs("", "decrypt", "-key", private, "-in", cipher, "-out", out); code != 0 {
+		t.Fatalf("decrypt exited with %d: %s", code, stderr)
+	}
+	if got, _ := os.ReadFile(out); string(got) != "Hello World!" {
+		t.Errorf("-out = %q, want %q", got, "Hello World!")
+	}
+}
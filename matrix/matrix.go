@@ -106,6 +106,16 @@ func NewMatrixFull(height, width int, v Vector) Matrix {
 	return m
 }
 
+// Copy returns a copy of m that shares none of its values.
+func (m Matrix) Copy() Matrix {
+	c := make([]Vector, m.Height())
+	for i := range m {
+		c[i] = m.Row(i)
+	}
+
+	return c
+}
+
 func (m Matrix) Height() int {
 	return len(m)
 }
@@ -89,3 +89,19 @@ func TestDotProduct(t *testing.T) {
 		})
 	}
 }
+
+func TestMatrix_Copy(t *testing.T) {
+	m := NewMatrixFull(2, 2, Vector{new(big.Rat).SetInt64(1), new(big.Rat).SetInt64(2),
+		new(big.Rat).SetInt64(3), new(big.Rat).SetInt64(4)})
+
+	c := m.Copy()
+	if !reflect.DeepEqual(c, m) {
+		t.Fatalf("Copy() = %v, want %v", c, m)
+	}
+
+	c[0][1].SetInt64(5)
+	c.SetCol(0, Vector{new(big.Rat).SetInt64(6), new(big.Rat).SetInt64(7)})
+	if m[0][0].Cmp(big.NewRat(1, 1)) != 0 || m[0][1].Cmp(big.NewRat(2, 1)) != 0 || m[1][0].Cmp(big.NewRat(3, 1)) != 0 {
+		t.Errorf("changing the copy changed m: %v", m)
+	}
+}